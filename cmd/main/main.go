@@ -2,12 +2,14 @@ package main
 
 import (
 	"flag"
-	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 
 	"discord_ladder_bot/internal/config"
 	"discord_ladder_bot/internal/discordbot"
+	"discord_ladder_bot/internal/logging"
+	"discord_ladder_bot/internal/webserver"
 )
 
 func main() {
@@ -23,6 +25,13 @@ func main() {
 		panic(err)
 	}
 
+	// log to stderr in the configured level and format
+	logger, err := logging.NewLogger(conf, os.Stderr)
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(logger)
+
 	// TODO: pass in database pointer and maybe OpenAI client pointer.
 	discord, err := discordbot.NewDiscordBot(conf)
 	if err != nil {
@@ -32,14 +41,26 @@ func main() {
 	if err2 != nil {
 		panic(err2)
 	}
-	fmt.Println("Discord bot started.")
+	slog.Info("discord bot started")
 	defer discord.Stop()
 
+	// Serve the ranking data over HTTP if an address is configured
+	if conf.HTTPAddress != "" {
+		web := webserver.NewWebServer(conf, discord.RankingData)
+		web.OnChange = discord.ChannelChanged
+		web.Webhooks = discord.Webhooks
+		err3 := web.Start()
+		if err3 != nil {
+			panic(err3)
+		}
+		defer web.Stop()
+	}
+
 	// Gracefully Shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
-	fmt.Println("Press Ctrl+C to exit")
+	slog.Info("press Ctrl+C to exit")
 	<-stop
 	signal.Reset(os.Interrupt)
-	fmt.Println("Exiting...")
+	slog.Info("exiting")
 }
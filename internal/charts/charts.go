@@ -0,0 +1,246 @@
+package charts
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"time"
+
+	"discord_ladder_bot/internal/rankingdata"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	marginLeft  = 50
+	marginRight = 20
+	marginTop   = 30
+	marginBot   = 40
+
+	boxHeight  = 30
+	boxGap     = 6
+	maxBoxSize = 160
+)
+
+var (
+	background = color.RGBA{0x2b, 0x2d, 0x31, 0xff}
+	foreground = color.RGBA{0xdb, 0xde, 0xe1, 0xff}
+	gridColor  = color.RGBA{0x4e, 0x50, 0x58, 0xff}
+	lineColor  = color.RGBA{0x58, 0x65, 0xf2, 0xff}
+
+	// colors cycled through for each pyramid tier
+	tierColors = []color.RGBA{
+		{0xf1, 0xc4, 0x0f, 0xff},
+		{0xbd, 0xc3, 0xc7, 0xff},
+		{0xe6, 0x7e, 0x22, 0xff},
+		{0x1a, 0xbc, 0x9c, 0xff},
+		{0x34, 0x98, 0xdb, 0xff},
+		{0x9b, 0x59, 0xb6, 0xff},
+	}
+)
+
+// function that renders a player's position over time as a PNG. The changes
+// must be in chronological order and belong to a single player.
+func PositionChart(title string, changes []rankingdata.PositionChange, now time.Time) ([]byte, error) {
+	if len(changes) == 0 {
+		return nil, errors.New("no position changes to chart")
+	}
+
+	img := newImage(chartWidth, chartHeight)
+	drawText(img, marginLeft, marginTop-12, title, foreground)
+
+	// find the range of the axes
+	start := changes[0].Date
+	end := now
+	if !end.After(start) {
+		end = start.Add(time.Hour)
+	}
+	maxPos := 1
+	for _, change := range changes {
+		if change.NewPosition > maxPos {
+			maxPos = change.NewPosition
+		}
+		if change.OldPosition > maxPos {
+			maxPos = change.OldPosition
+		}
+	}
+
+	plotW := chartWidth - marginLeft - marginRight
+	plotH := chartHeight - marginTop - marginBot
+	xFor := func(t time.Time) int {
+		return marginLeft + int(float64(plotW)*float64(t.Sub(start))/float64(end.Sub(start)))
+	}
+	// position 1 is at the top
+	yFor := func(pos int) int {
+		if maxPos == 1 {
+			return marginTop
+		}
+		return marginTop + (pos-1)*plotH/(maxPos-1)
+	}
+
+	// draw the grid and the position labels
+	step := (maxPos + 9) / 10
+	for pos := 1; pos <= maxPos; pos += step {
+		y := yFor(pos)
+		drawLine(img, marginLeft, y, chartWidth-marginRight, y, gridColor, 1)
+		drawText(img, 8, y+4, fmt.Sprintf("#%d", pos), foreground)
+	}
+	drawLine(img, marginLeft, marginTop, marginLeft, chartHeight-marginBot, foreground, 1)
+	drawLine(img, marginLeft, chartHeight-marginBot, chartWidth-marginRight, chartHeight-marginBot, foreground, 1)
+	drawText(img, marginLeft, chartHeight-marginBot+20, start.Format("2006-01-02"), foreground)
+	endLabel := end.Format("2006-01-02")
+	drawText(img, chartWidth-marginRight-textWidth(endLabel), chartHeight-marginBot+20, endLabel, foreground)
+
+	// draw the position as a step line, ending when the player left
+	x := xFor(start)
+	pos := changes[0].NewPosition
+	for _, change := range changes[1:] {
+		if pos == 0 {
+			x = xFor(change.Date)
+			pos = change.NewPosition
+			continue
+		}
+		nextX := xFor(change.Date)
+		drawLine(img, x, yFor(pos), nextX, yFor(pos), lineColor, 2)
+		if change.NewPosition != 0 {
+			drawLine(img, nextX, yFor(pos), nextX, yFor(change.NewPosition), lineColor, 2)
+		}
+		x = nextX
+		pos = change.NewPosition
+	}
+	if pos != 0 {
+		drawLine(img, x, yFor(pos), xFor(end), yFor(pos), lineColor, 2)
+	}
+
+	return encode(img)
+}
+
+// function that renders the ladder as a pyramid PNG, one row per tier
+func PyramidChart(title string, tiers [][]rankingdata.Player) ([]byte, error) {
+	if len(tiers) == 0 {
+		return nil, errors.New("no players to chart")
+	}
+
+	// size the boxes so the widest tier fits
+	widest := 0
+	for _, tier := range tiers {
+		if len(tier) > widest {
+			widest = len(tier)
+		}
+	}
+	boxW := (chartWidth - marginLeft - marginRight - (widest-1)*boxGap) / widest
+	if boxW > maxBoxSize {
+		boxW = maxBoxSize
+	}
+	height := marginTop + len(tiers)*(boxHeight+boxGap) + marginBot/2
+
+	img := newImage(chartWidth, height)
+	drawText(img, marginLeft, marginTop-12, title, foreground)
+
+	for t, tier := range tiers {
+		rowW := len(tier)*boxW + (len(tier)-1)*boxGap
+		x := (chartWidth - rowW) / 2
+		y := marginTop + t*(boxHeight+boxGap)
+		fill := tierColors[t%len(tierColors)]
+		for _, player := range tier {
+			rect := image.Rect(x, y, x+boxW, y+boxHeight)
+			draw.Draw(img, rect, &image.Uniform{fill}, image.Point{}, draw.Src)
+
+			label := fitText(fmt.Sprintf("%d. %s", player.Position, player.GameName), boxW-6)
+			drawText(img, x+3, y+boxHeight/2+4, label, background)
+			x += boxW + boxGap
+		}
+	}
+
+	return encode(img)
+}
+
+// function that creates an image filled with the background color
+func newImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+	return img
+}
+
+// function that encodes an image as PNG
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// function that draws text with its baseline at y
+func drawText(img *image.RGBA, x int, y int, text string, c color.Color) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  &image.Uniform{c},
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+// function that returns the width of text in pixels
+func textWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Ceil()
+}
+
+// function that truncates text to fit a width, cutting whole runes so
+// multi-byte names aren't split
+func fitText(text string, width int) string {
+	runes := []rune(text)
+	for len(runes) > 1 && textWidth(string(runes)) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// function that draws a line using Bresenham's algorithm
+func drawLine(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color, thickness int) {
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx := 1
+	if x0 > x1 {
+		sx = -1
+	}
+	sy := 1
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		for t := 0; t < thickness; t++ {
+			img.Set(x0+t, y0, c)
+			img.Set(x0, y0+t, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
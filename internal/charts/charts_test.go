@@ -0,0 +1,70 @@
+package charts
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"discord_ladder_bot/internal/rankingdata"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestPositionChart(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	changes := []rankingdata.PositionChange{
+		{PlayerID: "1234", OldPosition: 0, NewPosition: 6, Date: start},
+		{PlayerID: "1234", OldPosition: 6, NewPosition: 5, Date: start.Add(48 * time.Hour)},
+		{PlayerID: "1234", OldPosition: 5, NewPosition: 3, Date: start.Add(96 * time.Hour)},
+		{PlayerID: "1234", OldPosition: 3, NewPosition: 4, Date: start.Add(120 * time.Hour)},
+	}
+
+	data, err := PositionChart("Position of u1234", changes, start.Add(240*time.Hour))
+	if err != nil {
+		t.Fatalf("Error drawing chart: %s", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error decoding chart: %s", err)
+	}
+	assert.Equal(t, img.Bounds().Dx(), chartWidth)
+	assert.Equal(t, img.Bounds().Dy(), chartHeight)
+
+	if _, err := PositionChart("empty", nil, start); err == nil {
+		t.Errorf("Expected an error for an empty chart")
+	}
+}
+
+func TestPyramidChart(t *testing.T) {
+	tiers := [][]rankingdata.Player{
+		{{PlayerID: "1", GameName: "u1", Position: 1}},
+		{{PlayerID: "2", GameName: "u2", Position: 2}, {PlayerID: "3", GameName: "a very long game name", Position: 3}},
+		{{PlayerID: "4", GameName: "u4", Position: 4}},
+	}
+
+	data, err := PyramidChart("Current standings", tiers)
+	if err != nil {
+		t.Fatalf("Error drawing chart: %s", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error decoding chart: %s", err)
+	}
+	assert.Equal(t, img.Bounds().Dx(), chartWidth)
+	assert.Equal(t, img.Bounds().Dy(), marginTop+3*(boxHeight+boxGap)+marginBot/2)
+
+	if _, err := PyramidChart("empty", nil); err == nil {
+		t.Errorf("Expected an error for an empty chart")
+	}
+}
+
+func TestFitText(t *testing.T) {
+	// each character of the font is 7 pixels wide
+	assert.Equal(t, fitText("1. Zoë", 100), "1. Zoë")
+	assert.Equal(t, fitText("1. Zoë", 42), "1. Zoë")
+	assert.Equal(t, fitText("1. Zoëy", 42), "1. Zoë")
+	assert.Equal(t, utf8.ValidString(fitText("1. 日本語", 35)), true)
+	assert.Equal(t, fitText("1. 日本語", 35), "1. 日本")
+}
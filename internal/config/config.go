@@ -8,14 +8,28 @@ import (
 )
 
 type Config struct {
-	DiscordToken        string `yaml:"discord_token"`
-	LadderMode          string `yaml:"ladder_mode"`
-	OpenAIKey           string `yaml:"openai_key"`
-	MongoDBName         string `yaml:"mongo_db"`
-	MongoAdmin          string `yaml:"mongo_admin"`
-	MongoPass           string `yaml:"mongo_pass"`
-	MongoURI            string `yaml:"mongo_uri"`
-	MongoCollectionName string `yaml:"mongo_collection_name"`
+	DiscordToken        string    `yaml:"discord_token"`
+	LadderMode          string    `yaml:"ladder_mode"`
+	OpenAIKey           string    `yaml:"openai_key"`
+	MongoDBName         string    `yaml:"mongo_db"`
+	MongoAdmin          string    `yaml:"mongo_admin"`
+	MongoPass           string    `yaml:"mongo_pass"`
+	MongoURI            string    `yaml:"mongo_uri"`
+	MongoCollectionName string    `yaml:"mongo_collection_name"`
+	HTTPAddress         string    `yaml:"http_address"`    // e.g. ":8080", empty disables the web server
+	AdminAPIToken       string    `yaml:"admin_api_token"` // empty disables the admin API
+	Webhooks            []Webhook `yaml:"webhooks"`
+	LogLevel            string    `yaml:"log_level"`        // debug, info (default), warn or error
+	LogFormat           string    `yaml:"log_format"`       // text (default) or json
+	AuditChannelID      string    `yaml:"audit_channel_id"` // channel admin actions are mirrored to, empty disables
+	GuildIDs            []string  `yaml:"guild_ids"`        // register commands in these guilds only, empty registers globally
+}
+
+// Webhook is an outgoing HTTP endpoint that is sent ladder events
+type Webhook struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"` // used to sign the payload, see webhooks.Sign
+	Events []string `yaml:"events"` // event types to send, empty sends all events
 }
 
 // function that reads a json file and returns a Config struct
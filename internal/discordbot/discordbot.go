@@ -2,22 +2,35 @@ package discordbot
 
 import (
 	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/metrics"
 	"discord_ladder_bot/internal/rankingdata"
 	"discord_ladder_bot/internal/version"
+	"discord_ladder_bot/internal/webhooks"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 type commandHandler func(*rankingdata.ChannelRankingData,
 	*discordgo.InteractionCreate,
-	[]*discordgo.ApplicationCommandInteractionDataOption) (string, error)
+	[]*discordgo.ApplicationCommandInteractionDataOption) (*reply, error)
 
 type DiscordBot struct {
-	Discord     *discordgo.Session
-	RankingData *rankingdata.RankingData
-	commands    []*discordgo.ApplicationCommand
-	handlers    map[string]commandHandler
+	Discord        *discordgo.Session
+	RankingData    *rankingdata.RankingData
+	commands       []*discordgo.ApplicationCommand
+	handlers       map[string]commandHandler
+	Webhooks       *webhooks.Dispatcher
+	connects       atomic.Int32
+	auditChannelID string
+	roleSync       *roleSyncer
+	scheduler      *scheduler
+	guildIDs       []string                                   // guilds to register commands in, empty registers them globally
+	registered     map[string][]*discordgo.ApplicationCommand // commands registered at start up by guild ID, "" for global
 }
 
 // NewDiscordBot creates a new DiscordBot instance
@@ -34,6 +47,11 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 		return nil, err
 	}
 
+	// admin and moderator commands are hidden from members who can't manage the channel,
+	// server admins can give other roles access under Integrations (see ladminAccessNote)
+	adminPermissions := int64(discordgo.PermissionManageChannels)
+	dmPermission := false
+
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "help",
@@ -43,10 +61,6 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 			Name:        "init",
 			Description: "Initialize a 1v1 ranking tournament (one per channel).",
 		},
-		{
-			Name:        "delete_tournament",
-			Description: "Delete a 1v1 ranking tournament (admin only).",
-		},
 		{
 			Name:        "register",
 			Description: "Register a user to a 1v1 ranking tournament.",
@@ -81,7 +95,6 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 			Name:        "challenge",
 			Description: "Challenge a user to a for their position.",
 			Options: []*discordgo.ApplicationCommandOption{
-
 				{
 					Name:        "defender",
 					Type:        discordgo.ApplicationCommandOptionUser,
@@ -122,6 +135,18 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 					Description: "The alternate defender (admin only).",
 					Required:    false,
 				},
+				{
+					Name:        "challenge",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The challenge ID (required when in more than one challenge).",
+					Required:    false,
+				},
+				{
+					Name:        "score",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The score of the match (e.g. 3-1).",
+					Required:    false,
+				},
 			},
 		},
 		{
@@ -134,35 +159,53 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 					Description: "The alternate challenger (admin only).",
 					Required:    false,
 				},
+				{
+					Name:        "challenge",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The challenge ID (required when in more than one challenge).",
+					Required:    false,
+				},
 			},
 		},
 		{
-			Name:        "forfeit",
-			Description: "Forfeit a challenge (alternate to \"/result result:lost\").",
+			Name:        "schedule",
+			Description: "Propose times to play a challenge, the other player picks one.",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Name:        "alt_user",
-					Type:        discordgo.ApplicationCommandOptionUser,
-					Description: "The alternate challenger (admin only).",
+					Name:        "times",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "Up to 5 comma separated times, like 2024-06-01 18:00, 2024-06-02 20:30.",
+					Required:    true,
+				},
+				{
+					Name:        "timezone",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "Timezone of the times, like Europe/Berlin (default UTC).",
+					Required:    false,
+				},
+				{
+					Name:        "challenge",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The challenge ID (required when in more than one challenge).",
 					Required:    false,
 				},
 			},
 		},
 		{
-			Name:        "move",
-			Description: "Move a user to a different position in the ladder. (admin only)",
+			Name:        "forfeit",
+			Description: "Forfeit a challenge (alternate to \"/result result:lost\").",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Name:        "user",
+					Name:        "alt_user",
 					Type:        discordgo.ApplicationCommandOptionUser,
-					Description: "The user to move.",
-					Required:    true,
+					Description: "The alternate challenger (admin only).",
+					Required:    false,
 				},
 				{
-					Name:        "position",
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Description: "The position to move the user to.",
-					Required:    true,
+					Name:        "challenge",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The challenge ID (required when in more than one challenge).",
+					Required:    false,
 				},
 			},
 		},
@@ -186,6 +229,100 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 				},
 			},
 		},
+		{
+			Name:        "match",
+			Description: "Show the details of a match.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "id",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The match ID.",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "profile",
+			Description: "Show a player's profile and record.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Description: "The user to show (default: yourself).",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "h2h",
+			Description: "Show the head to head record between two players.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user_a",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Description: "The first player.",
+					Required:    true,
+				},
+				{
+					Name:        "user_b",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Description: "The second player.",
+					Required:    true,
+				},
+				{
+					Name:        "limit",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Description: "The number of recent meetings to show (default: 5).",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "timeline",
+			Description: "Show how a player's position changed over time.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "user",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Description: "The user to show (default: yourself).",
+					Required:    false,
+				},
+				{
+					Name:        "limit",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Description: "The number of changes to show (default: 10).",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "chart",
+			Description: "Draw a chart of the ladder or a player's position over time.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "type",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Description: "The chart to draw.",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{
+							Name:  "position",
+							Value: "position",
+						},
+						{
+							Name:  "pyramid",
+							Value: "pyramid",
+						},
+					},
+				},
+				{
+					Name:        "user",
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Description: "The user to chart (position only, default: yourself).",
+					Required:    false,
+				},
+			},
+		},
 		{
 			Name:        "user_settings",
 			Description: "Set a value in the ranking data.",
@@ -218,6 +355,30 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 					Description: "Notes to set.",
 					Required:    false,
 				},
+				{
+					Name:        "notify_challenged",
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Description: "Get a direct message when you are challenged.",
+					Required:    false,
+				},
+				{
+					Name:        "notify_results",
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Description: "Get a direct message when a result is reported against you.",
+					Required:    false,
+				},
+				{
+					Name:        "notify_deadlines",
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Description: "Get a direct message a day before a challenge deadline.",
+					Required:    false,
+				},
+				{
+					Name:        "notify_positions",
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Description: "Get a direct message when your position changes.",
+					Required:    false,
+				},
 				{
 					Name:        "alt_user",
 					Type:        discordgo.ApplicationCommandOptionUser,
@@ -227,128 +388,453 @@ func NewDiscordBot(conf *config.Config) (*DiscordBot, error) {
 			},
 		},
 		{
-			Name:        "system_settings",
-			Description: "Set system data (admin only).",
+			Name:                     "lmod",
+			Description:              "Ladder moderator commands, other roles need access under Server Settings > Integrations.",
+			DefaultMemberPermissions: &adminPermissions,
+			DMPermission:             &dmPermission,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
-					Name:        "mode",
-					Type:        discordgo.ApplicationCommandOptionString,
-					Description: "The challenge mode to set.",
-					Required:    false,
-					Choices: []*discordgo.ApplicationCommandOptionChoice{
+					Name:        "move",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Move a user to a different position in the ladder.",
+					Options: []*discordgo.ApplicationCommandOption{
 						{
-							Name:  "ladder",
-							Value: "ladder",
+							Name:        "user",
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Description: "The user to move.",
+							Required:    true,
 						},
 						{
-							Name:  "pyramid",
-							Value: "pyramid",
+							Name:        "position",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "The position to move the user to.",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:                     "ladmin",
+			Description:              "Ladder admin commands, other roles need access under Server Settings > Integrations.",
+			DefaultMemberPermissions: &adminPermissions,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "system_settings",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Set system data.",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "mode",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "The challenge mode to set.",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{
+									Name:  "ladder",
+									Value: "ladder",
+								},
+								{
+									Name:  "pyramid",
+									Value: "pyramid",
+								},
+								{
+									Name:  "open",
+									Value: "open",
+								},
+							},
+						},
+						{
+							Name:        "timeout",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "The challenge timeout in days to set.",
+							Required:    false,
+						},
+						{
+							Name:        "rematch_cooldown",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Hours a challenger must wait to rechallenge a defender they lost to (0 to disable).",
+							Required:    false,
+						},
+						{
+							Name:        "defense_immunity",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Hours a defender is protected from new challenges after a defense (0 to disable).",
+							Required:    false,
+						},
+						{
+							Name:        "max_positions_up",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Maximum positions a challenger may reach (0 for the mode default).",
+							Required:    false,
+						},
+						{
+							Name:        "max_tiers_up",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Maximum tiers a challenger may reach (0 for the mode default).",
+							Required:    false,
+						},
+						{
+							Name:        "max_percent_up",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Maximum percentage of the ladder a challenger may reach (0 to disable).",
+							Required:    false,
+						},
+						{
+							Name:        "skip_inactive",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Skip inactive players when counting positions and tiers.",
+							Required:    false,
 						},
 						{
-							Name:  "open",
-							Value: "open",
+							Name:        "allow_downward",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Allow challenging players below, positions only change on challenges up.",
+							Required:    false,
+						},
+						{
+							Name:        "max_outgoing",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Maximum outgoing challenges per player (0 for one challenge at a time).",
+							Required:    false,
+						},
+						{
+							Name:        "max_incoming",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Maximum incoming challenges per player (0 for one challenge at a time).",
+							Required:    false,
+						},
+						{
+							Name:        "admin_add",
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Description: "Add an admin.",
+							Required:    false,
+						},
+						{
+							Name:        "admin_remove",
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Description: "Remove an admin.",
+							Required:    false,
+						},
+						{
+							Name:        "admin_role_add",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Description: "Give a role admin rights.",
+							Required:    false,
+						},
+						{
+							Name:        "admin_role_remove",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Description: "Take admin rights from a role.",
+							Required:    false,
+						},
+						{
+							Name:        "moderator_role_add",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Description: "Give a role moderator rights (manage players, resolve disputes).",
+							Required:    false,
+						},
+						{
+							Name:        "moderator_role_remove",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Description: "Take moderator rights from a role.",
+							Required:    false,
+						},
+						{
+							Name:        "open_admin",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Give everyone admin rights.",
+							Required:    false,
+						},
+						{
+							Name:        "scheduled_events",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Add agreed match times to the server's events.",
+							Required:    false,
+						},
+						{
+							Name:        "leaderboard",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Keep a pinned leaderboard up to date in the channel.",
+							Required:    false,
+						},
+						{
+							Name:        "notes",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "Notes to set.",
+							Required:    false,
 						},
 					},
 				},
 				{
-					Name:        "timeout",
-					Type:        discordgo.ApplicationCommandOptionInteger,
-					Description: "The challenge timeout in days to set.",
-					Required:    false,
+					Name:        "rank_role",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Give a role to players in a range of positions or a pyramid tier.",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "role",
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Description: "The role to give out.",
+							Required:    true,
+						},
+						{
+							Name:        "positions",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "Positions that earn the role, like 1 or 1-10.",
+							Required:    false,
+						},
+						{
+							Name:        "tier",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Pyramid tier that earns the role.",
+							Required:    false,
+						},
+						{
+							Name:        "remove",
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Description: "Stop giving out the role and take it from everyone.",
+							Required:    false,
+						},
+					},
 				},
 				{
-					Name:        "admin_add",
-					Type:        discordgo.ApplicationCommandOptionUser,
-					Description: "Add an admin.",
-					Required:    false,
+					Name:        "digest",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Post the standings with a summary of the period on a schedule.",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "frequency",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "How often to post the digest.",
+							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{
+									Name:  "daily",
+									Value: "daily",
+								},
+								{
+									Name:  "weekly",
+									Value: "weekly",
+								},
+								{
+									Name:  "off",
+									Value: "off",
+								},
+							},
+						},
+						{
+							Name:        "time",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "Time of day to post, like 18:00 (default 18:00).",
+							Required:    false,
+						},
+						{
+							Name:        "timezone",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "Timezone of the time, like Europe/Berlin (default UTC).",
+							Required:    false,
+						},
+						{
+							Name:        "weekday",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Description: "Day to post weekly digests.",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{
+									Name:  "Monday",
+									Value: "monday",
+								},
+								{
+									Name:  "Tuesday",
+									Value: "tuesday",
+								},
+								{
+									Name:  "Wednesday",
+									Value: "wednesday",
+								},
+								{
+									Name:  "Thursday",
+									Value: "thursday",
+								},
+								{
+									Name:  "Friday",
+									Value: "friday",
+								},
+								{
+									Name:  "Saturday",
+									Value: "saturday",
+								},
+								{
+									Name:  "Sunday",
+									Value: "sunday",
+								},
+							},
+						},
+					},
 				},
 				{
-					Name:        "admin_remove",
-					Type:        discordgo.ApplicationCommandOptionUser,
-					Description: "Remove an admin.",
-					Required:    false,
+					Name:        "audit",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Show recent admin actions in this channel.",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "limit",
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Description: "Number of actions to show (default 10).",
+							Required:    false,
+						},
+						{
+							Name:        "user",
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Description: "Only show actions by or on this user.",
+							Required:    false,
+						},
+					},
 				},
 				{
-					Name:        "notes",
-					Type:        discordgo.ApplicationCommandOptionString,
-					Description: "Notes to set.",
-					Required:    false,
+					Name:        "printraw",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Print the raw data for the channel.",
+				},
+				{
+					Name:        "delete_tournament",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Delete a 1v1 ranking tournament.",
 				},
 			},
 		},
-		{
-			Name:        "printraw",
-			Description: "Print the raw data for the channel.",
-		},
 	}
 
 	handlers := map[string]commandHandler{
 
 		"help": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
 			var response string
 			response += "Commands:\n"
 			for _, cmd := range commands {
-				response += fmt.Sprintf("  /%s: %s\n", cmd.Name, cmd.Description)
+				// only list admin and moderator commands to those who can use them
+				if cmd.Name == "lmod" {
+					if !can(c, i, rankingdata.CapManagePlayers) {
+						continue
+					}
+				} else if cmd.DefaultMemberPermissions != nil && !can(c, i, rankingdata.CapEditSettings) &&
+					i.Member.Permissions&guildManagerPermissions == 0 {
+					continue
+				}
+				subcommands := 0
+				for _, option := range cmd.Options {
+					if option.Type == discordgo.ApplicationCommandOptionSubCommand {
+						response += fmt.Sprintf("  /%s %s: %s\n", cmd.Name, option.Name, option.Description)
+						subcommands++
+					}
+				}
+				if subcommands == 0 {
+					response += fmt.Sprintf("  /%s: %s\n", cmd.Name, cmd.Description)
+				}
+			}
+			if can(c, i, rankingdata.CapEditSettings) {
+				response += ladminAccessNote
+			}
+			if c != nil {
+				rules, err := c.PrintChallengeRules()
+				if err != nil {
+					return nil, err
+				}
+				response += rules
 			}
 			response += fmt.Sprintf("Version: %s\n", version.Version)
-			return response, nil
+			return private(response, nil)
 		},
 		"init": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
 			if c != nil {
-				return "Channel already initialized. If you'd like to reset, use !delete_tournament and then !init.", nil
+				return private("Channel already initialized. If you'd like to reset, use /ladmin delete_tournament and then /init.", nil)
 			}
-			return rankingDataPtr.AddChannel(i.ChannelID, i.Member.User.ID)
+			response, err := rankingDataPtr.AddChannel(i.ChannelID, i.Member.User.ID)
+			return public(response+"\n"+ladminAccessNote, err)
 		},
 		"delete_tournament": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to delete the tournament!", nil
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+			if !can(c, i, rankingdata.CapEditSettings) {
+				return private("You must be an admin to delete the tournament!", nil)
 			}
-			return rankingDataPtr.RemoveChannel(i.ChannelID)
+			before := fmt.Sprintf("%d players, %d active challenges", len(c.Standings()), len(c.Challenges()))
+			response, err := rankingDataPtr.RemoveChannel(i.ChannelID)
+			if err != nil {
+				return nil, err
+			}
+			rankingDataPtr.RecordAudit(rankingdata.AuditEntry{
+				ChannelID: i.ChannelID,
+				ActorID:   i.Member.User.ID,
+				Source:    "discord",
+				Action:    "delete_tournament",
+				Before:    before,
+			})
+			return public(response, nil)
 		},
-		"register":   handleRegister,
-		"unregister": handleUnregister,
-		"challenge":  handleChallenge,
-		"result":     handleResult,
-		"cancel":     handleCancel,
-		"forfeit":    handleForfeit,
-		"move":       handleMove,
+		"register":   audited(rankingDataPtr, handleRegister),
+		"unregister": audited(rankingDataPtr, handleUnregister),
+		"challenge":  audited(rankingDataPtr, handleChallenge),
+		"result":     audited(rankingDataPtr, handleResult),
+		"cancel":     audited(rankingDataPtr, handleCancel),
+		"forfeit":    audited(rankingDataPtr, handleForfeit),
+		"schedule":   handleSchedule,
+		"move":       audited(rankingDataPtr, handleMove),
 		"standings": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
-			return c.PrintRankings()
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+			return listing(c.PrintRankings())
 		},
 		"active_challenges": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
-			return c.PrintChallenges()
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+			return listing(c.PrintChallenges())
 		},
 		"history": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
 			// TODO handle limit
-			return c.PrintHistory()
+			return listing(c.PrintHistory())
 		},
-		"user_settings":   handleUserSettings,
-		"system_settings": handleSystemSettings,
+		"match":           handleMatch,
+		"profile":         handleProfile,
+		"h2h":             handleHeadToHead,
+		"timeline":        handleTimeline,
+		"chart":           handleChart,
+		"user_settings":   audited(rankingDataPtr, handleUserSettings),
+		"system_settings": audited(rankingDataPtr, handleSystemSettings),
+		"audit":           handleAudit(rankingDataPtr),
+		"digest":          audited(rankingDataPtr, handleDigest),
 		"printraw": func(c *rankingdata.ChannelRankingData,
 			i *discordgo.InteractionCreate,
-			o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
-			return c.PrintRaw()
+			o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+			if !can(c, i, rankingdata.CapEditSettings) {
+				return private("You must be an admin to print the raw data.", nil)
+			}
+			return private(c.PrintRaw())
 		},
 	}
 
 	bot := &DiscordBot{
-		Discord:     discord,
-		RankingData: rankingDataPtr,
-		commands:    commands,
-		handlers:    handlers,
+		Discord:        discord,
+		RankingData:    rankingDataPtr,
+		commands:       commands,
+		handlers:       handlers,
+		Webhooks:       webhooks.NewDispatcher(conf.Webhooks),
+		auditChannelID: conf.AuditChannelID,
+		guildIDs:       conf.GuildIDs,
 	}
+	rankingDataPtr.AuditHook = bot.mirrorAudit
+	bot.roleSync = newRoleSyncer(bot)
+	handlers["rank_role"] = audited(rankingDataPtr, bot.handleRankRole)
+	bot.scheduler = newScheduler(time.Minute)
+	bot.scheduler.add("reminders", bot.sendReminders)
+	bot.scheduler.add("digest", bot.postDigests)
+	bot.scheduler.add("deadlines", bot.warnDeadlines)
 
 	return bot, nil
 }
@@ -359,57 +845,110 @@ func (bot *DiscordBot) Start() error {
 	// Add handlers
 	bot.Discord.AddHandler(bot.handleMessageCreate)
 	bot.Discord.AddHandler(bot.handleInteractionCreate)
+	bot.Discord.AddHandler(bot.handleConnect)
+	bot.Discord.AddHandler(bot.handleResumed)
 
 	// Open connection to Discord
 	err := bot.Discord.Open()
 	if err != nil {
 		return err
 	}
+	bot.Webhooks.Start()
+	bot.updateChallengeMetrics()
 
-	oldguilds := bot.Discord.State.Guilds
-	for _, guild := range oldguilds {
-		fmt.Println("Found guild: ", guild.Name)
-		gcmds, err := bot.Discord.ApplicationCommands(bot.Discord.State.User.ID, guild.ID)
-		if err != nil {
-			for _, cmd := range gcmds {
-				fmt.Println("Deleting old command: ", cmd.Name, " in ", guild.Name)
-				bot.Discord.ApplicationCommandDelete(bot.Discord.State.User.ID, guild.ID, cmd.ID)
-			}
+	// reconcile rank roles with the ladders, they may have changed while we were offline
+	bot.roleSync.start()
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		bot.roleSync.queue(channelID)
+	}
+	bot.scheduler.start()
+
+	return bot.registerCommands()
+}
+
+// Stop the bot
+func (bot *DiscordBot) Stop() {
+	bot.unregisterCommands()
+	bot.Discord.Close()
+	bot.Webhooks.Stop()
+	bot.roleSync.stop()
+	bot.scheduler.stop()
+}
+
+// ChannelChanged saves the ranking data and sends out the channel's events.
+// It is called after anything changes a channel, from Discord or the web server.
+func (bot *DiscordBot) ChannelChanged(channel *rankingdata.ChannelRankingData) {
+	log := slog.Default()
+	if channel != nil {
+		log = log.With("channel", channel.ChannelID)
+	}
+	bot.channelChanged(log, channel)
+}
+
+// function that saves and sends out a channel's changes, logging with the caller's logger
+func (bot *DiscordBot) channelChanged(log *slog.Logger, channel *rankingdata.ChannelRankingData) {
+	// a deleted tournament is only saved, so its leaderboard isn't posted again
+	// and its roles aren't synced
+	if channel != nil {
+		if _, err := bot.RankingData.FindChannel(channel.ChannelID); err != nil {
+			channel = nil
 		}
 	}
 
-	oldcmds, err := bot.Discord.ApplicationCommands(bot.Discord.State.User.ID, "")
-	if err != nil {
-		for _, cmd := range oldcmds {
-			fmt.Println("Deleting old command: ", cmd.Name)
-			bot.Discord.ApplicationCommandDelete(bot.Discord.State.User.ID, "", cmd.ID)
+	var events []rankingdata.Event
+	if channel != nil {
+		// threads are made first so their IDs are saved with the challenge
+		events = channel.TakeEvents()
+		bot.updateThreads(log, channel, events)
+		bot.updateSchedules(log, channel, events)
+		bot.updateLeaderboard(log, channel, events)
+	}
+
+	// save early and often?
+	if err := bot.RankingData.Write(); err != nil {
+		log.Error("error saving ranking data", "error", err)
+	}
+
+	if channel != nil {
+		bot.Webhooks.Send(events)
+		bot.sendNotifications(log, channel, events)
+		if changesRanks(events) {
+			bot.roleSync.queue(channel.ChannelID)
 		}
 	}
+	bot.updateChallengeMetrics()
+}
 
-	for _, cmd := range bot.commands {
-		fmt.Println("Creating command: ", cmd.Name)
-		_, err := bot.Discord.ApplicationCommandCreate(bot.Discord.State.User.ID, "", cmd)
+// function that sets the active challenge gauge for every channel
+func (bot *DiscordBot) updateChallengeMetrics() {
+	metrics.ActiveChallenges.Reset()
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		channel, err := bot.RankingData.FindChannel(channelID)
 		if err != nil {
-			return err
+			continue
 		}
+		metrics.ActiveChallenges.WithLabelValues(channelID).Set(float64(len(channel.Challenges())))
 	}
-
-	return nil
 }
 
-// Stop the bot
-func (bot *DiscordBot) Stop() {
-	for _, command := range bot.commands {
-		bot.Discord.ApplicationCommandDelete(bot.Discord.State.User.ID, "", command.ID)
+// Handle a new gateway connection, every one after the first is a reconnect.
+// Resumed sessions connect too, so this is the only place reconnects are counted.
+func (bot *DiscordBot) handleConnect(s *discordgo.Session, c *discordgo.Connect) {
+	if bot.connects.Add(1) > 1 {
+		slog.Warn("reconnected to the discord gateway")
+		metrics.GatewayReconnects.Inc()
 	}
-	bot.Discord.Close()
+}
+
+// Handle a resumed gateway session
+func (bot *DiscordBot) handleResumed(s *discordgo.Session, r *discordgo.Resumed) {
+	slog.Warn("resumed the discord gateway session")
 }
 
 // Handle a message create event
 func (bot *DiscordBot) handleMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore all messages created by the bot itself
 	if m.Author.ID == s.State.User.ID {
-		//fmt.Println("Ignoring message from self")
 		return
 	}
 
@@ -420,6 +959,64 @@ func (bot *DiscordBot) handleMessageCreate(s *discordgo.Session, m *discordgo.Me
 	}
 }
 
+// commands that can take longer than Discord waits for a response, like
+// rank_role which checks every member of the guild
+var slowCommands = map[string]bool{
+	"rank_role": true,
+}
+
+// buttonHandler acts on a button click for a challenge, arg is the last part of the custom ID
+type buttonHandler func(c *rankingdata.ChannelRankingData, userID string, challengeID string, arg string) (string, error)
+
+// the button handlers by custom ID prefix
+var buttonHandlers = map[string]buttonHandler{
+	resultButtonPrefix:   resolveFromButton,
+	scheduleButtonPrefix: acceptFromButton,
+}
+
+// Handle a button click, custom IDs look like <prefix>:<channel>:<challenge>:<arg>
+func (bot *DiscordBot) handleButton(s *discordgo.Session, i *discordgo.InteractionCreate, log *slog.Logger) {
+	parts := strings.Split(i.MessageComponentData().CustomID, ":")
+	handler, ok := buttonHandlers[parts[0]]
+	if len(parts) != 4 || !ok || i.Member == nil {
+		return
+	}
+	command := parts[0] + "_button"
+	channelID, challengeID, arg := parts[1], parts[2], parts[3]
+	log = log.With("command", command, "challenge", challengeID)
+
+	start := time.Now()
+	outcome := "ok"
+	defer func() {
+		metrics.Commands.WithLabelValues(command, outcome).Inc()
+		metrics.Since(metrics.CommandDuration, start, command)
+	}()
+
+	c, err := bot.RankingData.FindChannel(channelID)
+	if err != nil {
+		outcome = "error"
+		respond(s, i, errorReply(err))
+		return
+	}
+
+	response, err := handler(c, i.Member.User.ID, challengeID, arg)
+	if err != nil {
+		outcome = "error"
+		log.Info("button rejected", "error", err)
+		respond(s, i, errorReply(err))
+		return
+	}
+	respond(s, i, &reply{Content: response})
+
+	// the buttons are usually in a thread, the ladder channel sees the outcome too
+	if i.ChannelID != c.ChannelID {
+		if _, err := s.ChannelMessageSend(c.ChannelID, response); err != nil {
+			log.Error("error posting to the ladder channel", "error", err)
+		}
+	}
+	bot.channelChanged(log, c)
+}
+
 // Handle a slash command
 func (bot *DiscordBot) handleInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
@@ -430,39 +1027,65 @@ func (bot *DiscordBot) handleInteractionCreate(s *discordgo.Session, i *discordg
 		return
 	}
 
+	// tag every log line with where the interaction came from
+	userID := ""
+	if i.Member != nil {
+		userID = i.Member.User.ID
+	} else if i.User != nil {
+		userID = i.User.ID
+	}
+	log := slog.With("interaction", i.ID, "guild", i.GuildID, "channel", i.ChannelID, "user", userID)
+
+	if i.Type == discordgo.InteractionMessageComponent {
+		bot.handleButton(s, i, log)
+		return
+	}
+
 	if i.Type != discordgo.InteractionApplicationCommand {
 		return
 	}
 
 	if i.Member == nil {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "This bot is intended to be used from a Server.",
-			},
-		})
+		log.Debug("ignoring command outside of a server")
+		respond(s, i, &reply{Content: "This bot is intended to be used from a Server.", Ephemeral: true})
 		return
 	}
 
 	// get the command data
 	data := i.ApplicationCommandData()
 
-	// get the subcommand
+	// get the subcommand, admin and moderator commands are subcommands of /ladmin and /lmod
 	command := data.Name
+	options := data.Options
+	if len(options) == 1 && options[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+		command = options[0].Name
+		options = options[0].Options
+	}
+	log = log.With("command", command)
+
+	// count and log the command and how long it took, the outcome is set below
+	start := time.Now()
+	outcome := "ok"
+	var failure error
+	defer func() {
+		metrics.Commands.WithLabelValues(command, outcome).Inc()
+		metrics.Since(metrics.CommandDuration, start, command)
+		if failure != nil {
+			log.Info("command handled", "outcome", outcome, "duration", time.Since(start), "error", failure)
+		} else {
+			log.Info("command handled", "outcome", outcome, "duration", time.Since(start))
+		}
+	}()
 
 	handler, ok := bot.handlers[command]
 	if !ok {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Invalid slash command: " + command,
-			},
-		})
-		err := bot.Discord.ApplicationCommandDelete(bot.Discord.State.User.ID, "", data.ID)
+		outcome = "invalid"
+		respond(s, i, &reply{Content: "Invalid slash command: " + command, Ephemeral: true})
+		err := bot.deleteCommand(i.GuildID, data.ID)
 		if err != nil {
-			fmt.Println("Error deleting invalid command: ", err)
+			log.Error("error deleting invalid command", "error", err)
 		} else {
-			fmt.Println("Deleted invalid command: ", command)
+			log.Info("deleted invalid command")
 		}
 		return
 	}
@@ -470,47 +1093,36 @@ func (bot *DiscordBot) handleInteractionCreate(s *discordgo.Session, i *discordg
 	// get the channel data
 	channel, err := bot.RankingData.FindChannel(i.ChannelID)
 	if err != nil && command != "init" {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: err.Error(),
-			},
-		})
+		outcome = "error"
+		failure = err
+		respond(s, i, errorReply(err))
 		return
 	}
 
-	// call the handler
-	response, err2 := handler(channel, i, data.Options)
-	if err2 != nil {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: err2.Error(),
-			},
-		})
-		return
+	// slow commands are answered right away and the reply follows
+	send := respond
+	if slowCommands[command] {
+		if err := deferResponse(s, i); err != nil {
+			log.Error("error deferring the response", "error", err)
+		}
+		send = respondDeferred
 	}
 
-	// determine if we should limit mentions in noisy output commands
-	if command == "standings" || command == "active_challenges" || command == "history" {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: response,
-				AllowedMentions: &discordgo.MessageAllowedMentions{
-					Parse: []discordgo.AllowedMentionType{},
-				},
-			},
-		})
-	} else {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: response,
-			},
-		})
+	// call the handler, errors are only shown to the member who ran the command
+	response, err := handler(channel, i, options)
+	if err != nil {
+		outcome = "error"
+		failure = err
+		send(s, i, errorReply(err))
+		// save whatever a handler applied before it failed, like the first of several settings
+		if channel != nil {
+			bot.channelChanged(log, channel)
+		}
+		return
+	}
+	if err := send(s, i, response); err != nil {
+		log.Error("error responding to command", "error", err)
 	}
 
-	// save early and often?
-	bot.RankingData.Write()
+	bot.channelChanged(log, channel)
 }
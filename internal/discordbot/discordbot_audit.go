@@ -0,0 +1,138 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// auditedCommandHandler is a command handler that can perform admin actions.
+// It fills in the audit entry's Action (and target, before and after) when it
+// does, and the entry is recorded if the Action is set, even if the handler
+// failed after changing some settings.
+type auditedCommandHandler func(*rankingdata.ChannelRankingData,
+	*discordgo.InteractionCreate,
+	[]*discordgo.ApplicationCommandInteractionDataOption,
+	*rankingdata.AuditEntry) (*reply, error)
+
+// function that wraps an audited handler so it records its admin actions
+func audited(rankingData *rankingdata.RankingData, handler auditedCommandHandler) commandHandler {
+	return func(c *rankingdata.ChannelRankingData,
+		i *discordgo.InteractionCreate,
+		o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+		entry := rankingdata.AuditEntry{
+			ChannelID: i.ChannelID,
+			ActorID:   i.Member.User.ID,
+			Source:    "discord",
+		}
+		response, err := handler(c, i, o, &entry)
+		if entry.Action != "" {
+			rankingData.RecordAudit(entry)
+		}
+		return response, err
+	}
+}
+
+// function that posts an audit entry to the configured log channel
+func (bot *DiscordBot) mirrorAudit(entry rankingdata.AuditEntry) {
+	if bot.auditChannelID == "" {
+		return
+	}
+	_, err := bot.Discord.ChannelMessageSendComplex(bot.auditChannelID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<#%s> %s", entry.ChannelID, entry.String()),
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{},
+		},
+	})
+	if err != nil {
+		slog.Error("error mirroring audit entry", "channel", entry.ChannelID, "action", entry.Action, "error", err)
+	}
+}
+
+func handleAudit(rankingData *rankingdata.RankingData) commandHandler {
+	return func(c *rankingdata.ChannelRankingData,
+		i *discordgo.InteractionCreate,
+		o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+		if !can(c, i, rankingdata.CapEditSettings) {
+			return private("You must be an admin to view the audit log.", nil)
+		}
+
+		limit := 10
+		userID := ""
+		for _, option := range o {
+			switch option.Name {
+			case "limit":
+				limit = int(option.IntValue())
+			case "user":
+				userID = option.UserValue(nil).ID
+			default:
+				return nil, fmt.Errorf("invalid option to view the audit log: %s", option.Name)
+			}
+		}
+		if limit < 1 || limit > 50 {
+			return nil, fmt.Errorf("limit must be between 1 and 50")
+		}
+		return listing(rankingData.PrintAudit(c.ChannelID, userID, limit))
+	}
+}
+
+// function that describes a player's settings for the audit log
+func describePlayerSettings(player rankingdata.Player) string {
+	return fmt.Sprintf("gamename: %s, status: %s, notes: %s, notifications: %s",
+		player.GameName, player.Status, player.Notes, player.Notifications())
+}
+
+// function that describes the current value of a system setting for the audit log
+func describeSetting(c *rankingdata.ChannelRankingData, name string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	switch name {
+	case "mode":
+		return c.ChallengeMode
+	case "timeout":
+		return fmt.Sprintf("%d days", int(c.ChallengeTimeoutDays.Hours()/24))
+	case "rematch_cooldown":
+		return fmt.Sprintf("%d hours", int(c.RematchCooldown.Hours()))
+	case "defense_immunity":
+		return fmt.Sprintf("%d hours", int(c.DefenseImmunity.Hours()))
+	case "max_positions_up":
+		return fmt.Sprint(c.MaxPositionsUp)
+	case "max_tiers_up":
+		return fmt.Sprint(c.MaxTiersUp)
+	case "max_percent_up":
+		return fmt.Sprint(c.MaxPercentUp)
+	case "skip_inactive":
+		return fmt.Sprint(c.SkipInactive)
+	case "allow_downward":
+		return fmt.Sprint(c.AllowDownward)
+	case "max_outgoing":
+		return fmt.Sprint(c.MaxOutgoing)
+	case "max_incoming":
+		return fmt.Sprint(c.MaxIncoming)
+	case "admin_add", "admin_remove":
+		admins := []string{}
+		for _, admin := range c.Admins {
+			admins = append(admins, fmt.Sprintf("<@%s>", admin))
+		}
+		return strings.Join(admins, " ")
+	case "admin_role_add", "admin_role_remove":
+		return rankingdata.RoleMentions(c.AdminRoles)
+	case "moderator_role_add", "moderator_role_remove":
+		return rankingdata.RoleMentions(c.ModeratorRoles)
+	case "open_admin":
+		return fmt.Sprint(c.OpenAdmin)
+	case "scheduled_events":
+		return fmt.Sprint(c.ScheduledEvents)
+	case "leaderboard":
+		return fmt.Sprint(c.Leaderboard)
+	case "notes":
+		return c.Notes
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package discordbot
+
+import (
+	"log/slog"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// function that registers the slash commands, in the configured guilds or
+// globally. A bulk overwrite makes Discord add, update and remove commands to
+// match ours, and commands left in the other scope are cleared so none show twice.
+func (bot *DiscordBot) registerCommands() error {
+	appID := bot.Discord.State.User.ID
+	scopes := bot.guildIDs
+	stale := []string{""}
+	if len(scopes) == 0 {
+		scopes = []string{""}
+		stale = []string{}
+		for _, guild := range bot.Discord.State.Guilds {
+			stale = append(stale, guild.ID)
+		}
+	}
+
+	for _, guildID := range stale {
+		_, err := bot.Discord.ApplicationCommandBulkOverwrite(appID, guildID, []*discordgo.ApplicationCommand{})
+		if err != nil {
+			slog.Warn("error clearing old commands", "guild", guildID, "error", err)
+		}
+	}
+
+	bot.registered = map[string][]*discordgo.ApplicationCommand{}
+	for _, guildID := range scopes {
+		created, err := bot.Discord.ApplicationCommandBulkOverwrite(appID, guildID, bot.commands)
+		if err != nil {
+			return err
+		}
+		bot.registered[guildID] = created
+		slog.Info("registered commands", "guild", guildID, "count", len(created))
+	}
+	return nil
+}
+
+// function that removes the commands registered at start up
+func (bot *DiscordBot) unregisterCommands() {
+	appID := bot.Discord.State.User.ID
+	for guildID, commands := range bot.registered {
+		for _, command := range commands {
+			if err := bot.Discord.ApplicationCommandDelete(appID, guildID, command.ID); err != nil {
+				slog.Warn("error deleting command", "guild", guildID, "command", command.Name, "error", err)
+			}
+		}
+	}
+	bot.registered = nil
+}
+
+// function that deletes a command we don't handle, it may be left over in the
+// guild it was used in or registered globally
+func (bot *DiscordBot) deleteCommand(guildID string, commandID string) error {
+	appID := bot.Discord.State.User.ID
+	if guildID != "" {
+		if err := bot.Discord.ApplicationCommandDelete(appID, guildID, commandID); err == nil {
+			return nil
+		}
+	}
+	return bot.Discord.ApplicationCommandDelete(appID, "", commandID)
+}
@@ -0,0 +1,72 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func handleDigest(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
+
+	if !can(c, i, rankingdata.CapEditSettings) {
+		return private("You must be an admin to schedule the standings digest.", nil)
+	}
+
+	frequency := ""
+	at := "18:00"
+	timezone := "UTC"
+	weekday := ""
+	for _, option := range o {
+		switch option.Name {
+		case "frequency":
+			frequency = option.StringValue()
+		case "time":
+			at = option.StringValue()
+		case "timezone":
+			timezone = option.StringValue()
+		case "weekday":
+			weekday = option.StringValue()
+		default:
+			return nil, fmt.Errorf("invalid option to schedule the digest: %s", option.Name)
+		}
+	}
+
+	before := c.DigestSchedule()
+	response, err := c.SetDigest(frequency, at, timezone, weekday)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = "digest"
+	entry.Before = before
+	entry.After = c.DigestSchedule()
+	return public(response, nil)
+}
+
+// function that posts the standings digest of every channel that is due, run by the scheduler
+func (bot *DiscordBot) postDigests(now time.Time) error {
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		c, err := bot.RankingData.FindChannel(channelID)
+		if err != nil {
+			continue
+		}
+		content, messageID, due := c.DueDigest(now)
+		if !due {
+			continue
+		}
+		// the digest stays due, and is retried on the next run, until it is posted
+		newID, err := bot.editPinned(c.ChannelID, messageID, content)
+		if err != nil {
+			slog.Error("error posting standings digest", "channel", channelID, "error", err)
+			continue
+		}
+		c.MarkDigestPosted(now, newID)
+		bot.ChannelChanged(c)
+	}
+	return nil
+}
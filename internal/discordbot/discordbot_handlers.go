@@ -1,16 +1,21 @@
 package discordbot
 
 import (
+	"bytes"
+	"discord_ladder_bot/internal/charts"
 	"discord_ladder_bot/internal/rankingdata"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 func handleRegister(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	playerID := i.Member.User.ID
 	gamename := ""
@@ -19,52 +24,74 @@ func handleRegister(c *rankingdata.ChannelRankingData,
 		case "alt_user":
 			if option.Type == discordgo.ApplicationCommandOptionUser {
 				// this is optional, we user the user who sent the message if not specified
-				if !c.IsAdmin(i.Member.User.ID) {
-					return "You must be an admin to register other users.", nil
+				if !can(c, i, rankingdata.CapManagePlayers) {
+					return private("You must be an admin or moderator to register other users.", nil)
 				}
 				playerID = option.UserValue(nil).ID
 			} else {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
 
 		case "gamename":
 			gamename = option.StringValue()
 		default:
-			return "", errors.New("invalid option to register user: " + option.Name)
+			return nil, errors.New("invalid option to register user: " + option.Name)
 		}
 	}
 	if gamename == "" {
 		gamename = "Unknown"
 	}
 
-	return c.AddPlayer(playerID, gamename)
+	response, err := c.AddPlayer(playerID, gamename)
+	if err != nil {
+		return nil, err
+	}
+	if playerID != i.Member.User.ID {
+		entry.Action = "register"
+		entry.TargetID = playerID
+		entry.After = c.DescribePosition(playerID)
+	}
+	return public(response, nil)
 }
 
 func handleUnregister(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	playerID := i.Member.User.ID
 	for _, option := range o {
 		switch option.Name {
 		case "alt_user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to unregister other users.", nil
+			if !can(c, i, rankingdata.CapManagePlayers) {
+				return private("You must be an admin or moderator to unregister other users.", nil)
 			}
 			playerID = option.UserValue(nil).ID
 		default:
-			return "", errors.New("invalid option to unregister user: " + option.Name)
+			return nil, errors.New("invalid option to unregister user: " + option.Name)
 		}
 	}
-	return c.RemovePlayer(playerID)
+
+	before := c.DescribePosition(playerID)
+	response, err := c.RemovePlayer(playerID)
+	if err != nil {
+		return nil, err
+	}
+	if playerID != i.Member.User.ID {
+		entry.Action = "unregister"
+		entry.TargetID = playerID
+		entry.Before = before
+	}
+	return public(response, nil)
 }
 
 func handleChallenge(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	challengerID := i.Member.User.ID
 	defenderID := ""
@@ -72,103 +99,152 @@ func handleChallenge(c *rankingdata.ChannelRankingData,
 		switch option.Name {
 		case "alt_user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to unregister other users.", nil
+			if !can(c, i, rankingdata.CapResolveDisputes) {
+				return private("You must be an admin or moderator to challenge for other users.", nil)
 			}
 			challengerID = option.UserValue(nil).ID
 		case "defender":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
 			defenderID = option.UserValue(nil).ID
 		default:
-			return "", errors.New("invalid option to challenge user: " + option.Name)
+			return nil, errors.New("invalid option to challenge user: " + option.Name)
 		}
 	}
 	if defenderID == "" {
-		return "Please specify a defender to challenge.", nil
+		return private("Please specify a defender to challenge.", nil)
 	}
 
-	return c.StartChallenge(challengerID, defenderID)
+	response, err := c.StartChallenge(challengerID, defenderID)
+	if err != nil {
+		return nil, err
+	}
+	if challengerID != i.Member.User.ID {
+		entry.Action = "challenge"
+		entry.TargetID = challengerID
+		entry.After = fmt.Sprintf("challenged <@%s>", defenderID)
+	}
+	return public(response, nil)
 }
 
 func handleResult(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	result := ""
+	score := ""
+	challengeID := ""
 	playerID := i.Member.User.ID
 	for _, option := range o {
 		switch option.Name {
 		case "alt_user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to set results for other users.", nil
+			if !can(c, i, rankingdata.CapResolveDisputes) {
+				return private("You must be an admin or moderator to set results for other users.", nil)
 			}
 			playerID = option.UserValue(nil).ID
 		case "result":
 			result = option.StringValue()
 			if result != "won" && result != "lost" {
-				return "Please specify a valid result (won, lost)", nil
+				return private("Please specify a valid result (won, lost)", nil)
 			}
+		case "challenge":
+			challengeID = option.StringValue()
+		case "score":
+			score = option.StringValue()
 		default:
-			return "", errors.New("invalid option to set challenge result: " + option.Name)
+			return nil, errors.New("invalid option to set challenge result: " + option.Name)
 		}
 	}
 
-	return c.ResolveChallenge(playerID, result)
+	return resolveFor(c, i, entry, playerID, challengeID, result, score)
+}
+
+// function that resolves a challenge, auditing it if an admin reported for another player
+func resolveFor(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	entry *rankingdata.AuditEntry,
+	playerID string, challengeID string, action string, score string) (*reply, error) {
+
+	if playerID == i.Member.User.ID {
+		return public(c.ResolveChallenge(playerID, challengeID, action, score))
+	}
+
+	response, err := c.ResolveChallengeFor(i.Member.User.ID, playerID, challengeID, action, score)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = "resolve"
+	entry.TargetID = playerID
+	entry.Before = "active"
+	entry.After = action
+	if score != "" {
+		entry.After += " " + score
+	}
+	return public(response, nil)
 }
 
 func handleCancel(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	playerID := i.Member.User.ID
+	challengeID := ""
 	for _, option := range o {
 		switch option.Name {
 		case "alt_user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to cancel challenges for other users.", nil
+			if !can(c, i, rankingdata.CapResolveDisputes) {
+				return private("You must be an admin or moderator to cancel challenges for other users.", nil)
 			}
 			playerID = option.UserValue(nil).ID
+		case "challenge":
+			challengeID = option.StringValue()
 		default:
-			return "", errors.New("invalid option to cancel challenge: " + option.Name)
+			return nil, errors.New("invalid option to cancel challenge: " + option.Name)
 		}
 	}
-	return c.ResolveChallenge(playerID, "cancel")
+	return resolveFor(c, i, entry, playerID, challengeID, "cancel", "")
 }
 
 func handleForfeit(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 	playerID := i.Member.User.ID
+	challengeID := ""
 	for _, option := range o {
 		switch option.Name {
 		case "alt_user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to forfeit challenges for other users.", nil
+			if !can(c, i, rankingdata.CapResolveDisputes) {
+				return private("You must be an admin or moderator to forfeit challenges for other users.", nil)
 			}
 			playerID = option.UserValue(nil).ID
+		case "challenge":
+			challengeID = option.StringValue()
 		default:
-			return "", errors.New("invalid option to forfeit challenge: " + option.Name)
+			return nil, errors.New("invalid option to forfeit challenge: " + option.Name)
 		}
 	}
-	return c.ResolveChallenge(playerID, "forfeit")
+	return resolveFor(c, i, entry, playerID, challengeID, "forfeit", "")
 }
 
 func handleUserSettings(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	playerID := i.Member.User.ID
 
@@ -177,43 +253,57 @@ func handleUserSettings(c *rankingdata.ChannelRankingData,
 	for _, option := range o {
 		if option.Name == "alt_user" && option.Type == discordgo.ApplicationCommandOptionUser {
 			// this is optional, we user the user who sent the message if not specified
-			if !c.IsAdmin(i.Member.User.ID) {
-				return "You must be an admin to set other users.", nil
+			if !can(c, i, rankingdata.CapManagePlayers) {
+				return private("You must be an admin or moderator to set other users.", nil)
 			}
 			playerID = option.UserValue(nil).ID
 		}
 	}
 
-	// loop through other options
+	// remember the old settings for the audit log
+	old, err := c.FindPlayer(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// loop through other options, stopping at the first that fails
 	for _, option := range o {
 
 		switch option.Name {
 		case "alt_user":
 			// already handled above
 		case "status":
-			err := c.SetPlayerStatus(playerID, option.StringValue())
-			if err != nil {
-				return "", err
-			}
+			err = c.SetPlayerStatus(playerID, option.StringValue())
 		case "gamename":
-			err := c.SetPlayerGameName(playerID, option.StringValue())
-			if err != nil {
-				return "", err
-			}
+			err = c.SetPlayerGameName(playerID, option.StringValue())
 		case "notes":
-			err := c.SetPlayerNotes(playerID, option.StringValue())
-			if err != nil {
-				return "", err
-			}
+			err = c.SetPlayerNotes(playerID, option.StringValue())
+		case "notify_challenged", "notify_results", "notify_deadlines", "notify_positions":
+			kind := strings.TrimPrefix(option.Name, "notify_")
+			err = c.SetPlayerNotification(playerID, kind, option.BoolValue())
 		default:
-			return "", fmt.Errorf("invalid option to set user settings: %s", option.Name)
+			err = fmt.Errorf("invalid option to set user settings: %s", option.Name)
+		}
+		if err != nil {
+			break
 		}
 	}
+	failed := err
 
-	// get the updated settings
+	// get the updated settings, and record what changed even if an option failed
 	player, err := c.FindPlayer(playerID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	before, after := describePlayerSettings(old), describePlayerSettings(player)
+	if playerID != i.Member.User.ID && before != after {
+		entry.Action = "user_settings"
+		entry.TargetID = playerID
+		entry.Before = before
+		entry.After = after
+	}
+	if failed != nil {
+		return nil, failed
 	}
 
 	// return the updated settings
@@ -222,102 +312,300 @@ func handleUserSettings(c *rankingdata.ChannelRankingData,
 	response += fmt.Sprintf("  gamename: %s\n", player.GameName)
 	response += fmt.Sprintf("  status: %s\n", player.Status)
 	response += fmt.Sprintf("  notes: %s\n", player.Notes)
-	return response, nil
+	response += fmt.Sprintf("  notifications: %s\n", player.Notifications())
+	return private(response, nil)
 }
 
 func handleSystemSettings(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
-	if !c.IsAdmin(i.Member.User.ID) {
-		return "You must be an admin to set system settings.", nil
+	if !can(c, i, rankingdata.CapEditSettings) {
+		return private("You must be an admin to set system settings.", nil)
+	}
+
+	// remember the old values for the audit log
+	var before, after []string
+	for _, option := range o {
+		before = append(before, option.Name+": "+describeSetting(c, option.Name))
 	}
 
-	// loop through options
+	// loop through options, stopping at the first that fails
 	// we don't need to check for user, since only admins can set system settings
+	var err error
+	applied := 0
 	for _, option := range o {
 		switch option.Name {
 		case "mode":
-			err := c.SetGameMode(option.StringValue())
-			if err != nil {
-				return "", err
-			}
-		case "timeoutdays":
-			err := c.SetTimeout(int(option.IntValue()))
-			if err != nil {
-				return "", err
-			}
+			err = c.SetGameMode(option.StringValue())
+		case "timeout":
+			err = c.SetTimeout(int(option.IntValue()))
+		case "rematch_cooldown":
+			err = c.SetRematchCooldown(int(option.IntValue()))
+		case "defense_immunity":
+			err = c.SetDefenseImmunity(int(option.IntValue()))
+		case "max_positions_up":
+			err = c.SetMaxPositionsUp(int(option.IntValue()))
+		case "max_tiers_up":
+			err = c.SetMaxTiersUp(int(option.IntValue()))
+		case "max_percent_up":
+			err = c.SetMaxPercentUp(int(option.IntValue()))
+		case "skip_inactive":
+			c.SetSkipInactive(option.BoolValue())
+		case "allow_downward":
+			c.SetAllowDownward(option.BoolValue())
+		case "max_outgoing":
+			err = c.SetMaxOutgoing(int(option.IntValue()))
+		case "max_incoming":
+			err = c.SetMaxIncoming(int(option.IntValue()))
 		case "admin_add":
-			err := c.AddAdmin(option.UserValue(nil).ID)
-			if err != nil {
-				return err.Error(), nil
-			}
+			err = c.AddAdmin(option.UserValue(nil).ID)
 		case "admin_remove":
-			err := c.RemoveAdmin(option.UserValue(nil).ID)
-			if err != nil {
-				return err.Error(), nil
-			}
+			err = c.RemoveAdmin(option.UserValue(nil).ID)
+		case "admin_role_add", "moderator_role_add":
+			kind := strings.TrimSuffix(option.Name, "_role_add")
+			err = c.AddRole(kind, option.RoleValue(nil, "").ID)
+		case "admin_role_remove", "moderator_role_remove":
+			kind := strings.TrimSuffix(option.Name, "_role_remove")
+			err = c.RemoveRole(kind, option.RoleValue(nil, "").ID)
+		case "open_admin":
+			c.SetOpenAdmin(option.BoolValue())
+		case "scheduled_events":
+			c.SetScheduledEvents(option.BoolValue())
+		case "leaderboard":
+			c.SetLeaderboard(option.BoolValue())
 		case "notes":
-			err := c.SetNotes(option.StringValue())
-			if err != nil {
-				return err.Error(), nil
-			}
+			err = c.SetNotes(option.StringValue())
 		default:
-			return "", fmt.Errorf("invalid option to set system settings: %s", option.Name)
+			err = fmt.Errorf("invalid option to set system settings: %s", option.Name)
+		}
+		if err != nil {
+			break
 		}
+		applied++
 	}
-	var response string
-	response += "Game settings:\n"
-	response += fmt.Sprintf("  gamemode: %s\n", c.ChallengeMode)
-	response += fmt.Sprintf("  timeout: %d (days)\n", c.ChallengeTimeoutDays)
-	response += "  admins: "
-	for _, admin := range c.Admins {
-		response += fmt.Sprintf("<@%s> ", admin)
-	}
-	response += "\n"
-	response += fmt.Sprintf("  notes: %s\n", c.Notes)
-	return response, nil
+
+	// record the options that were applied, even if a later one failed
+	for _, option := range o[:applied] {
+		after = append(after, option.Name+": "+describeSetting(c, option.Name))
+	}
+	if applied > 0 {
+		entry.Action = "system_settings"
+		entry.Before = strings.Join(before[:applied], ", ")
+		entry.After = strings.Join(after, ", ")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return listing(c.PrintSettings())
 }
 
 func handleMove(c *rankingdata.ChannelRankingData,
 	i *discordgo.InteractionCreate,
-	o []*discordgo.ApplicationCommandInteractionDataOption) (string, error) {
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
 
 	playerID := ""
 	position := -1
 
-	if !c.IsAdmin(i.Member.User.ID) {
-		return "You must be an admin to move players.", nil
+	if !can(c, i, rankingdata.CapManagePlayers) {
+		return private("You must be an admin or moderator to move players.", nil)
 	}
 
 	error_response := "Please specify a player and a position."
 	if len(o) != 2 {
-		return error_response, nil
+		return private(error_response, nil)
 	}
 
 	for _, option := range o {
 		switch option.Name {
 		case "user":
 			if option.Type != discordgo.ApplicationCommandOptionUser {
-				return "", errors.New("internal error, unexpected option type, expected discord user")
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
 			}
 			playerID = option.UserValue(nil).ID
 		case "position":
 			if option.Type != discordgo.ApplicationCommandOptionInteger {
-				return "", errors.New("internal error, unexpected option type, expected integer")
+				return nil, errors.New("internal error, unexpected option type, expected integer")
 			}
 			position = int(option.IntValue())
 		default:
-			return "", fmt.Errorf("invalid option to move player: %s", option.Name)
+			return nil, fmt.Errorf("invalid option to move player: %s", option.Name)
 		}
 	}
 
 	if position <= 0 {
-		return "", errors.New("position must be greater than 0")
+		return nil, errors.New("position must be greater than 0")
 	}
 	if playerID == "" {
-		return "", errors.New("player not specified")
+		return nil, errors.New("player not specified")
+	}
+
+	before := c.DescribePosition(playerID)
+	response, err := c.MovePlayer(playerID, position)
+	if err != nil {
+		return nil, err
+	}
+	entry.Action = "move"
+	entry.TargetID = playerID
+	entry.Before = before
+	entry.After = c.DescribePosition(playerID)
+	return public(response, nil)
+}
+
+func handleMatch(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	challengeID := ""
+	for _, option := range o {
+		switch option.Name {
+		case "id":
+			challengeID = option.StringValue()
+		default:
+			return nil, fmt.Errorf("invalid option to show match: %s", option.Name)
+		}
+	}
+	if challengeID == "" {
+		return private("Please specify a match ID.", nil)
+	}
+
+	return listing(c.PrintMatch(challengeID))
+}
+
+func handleProfile(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	playerID := i.Member.User.ID
+	for _, option := range o {
+		switch option.Name {
+		case "user":
+			if option.Type != discordgo.ApplicationCommandOptionUser {
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
+			}
+			playerID = option.UserValue(nil).ID
+		default:
+			return nil, fmt.Errorf("invalid option to show profile: %s", option.Name)
+		}
+	}
+
+	return listing(c.PrintProfile(playerID))
+}
+
+func handleHeadToHead(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	playerA := ""
+	playerB := ""
+	limit := 5
+	for _, option := range o {
+		switch option.Name {
+		case "user_a", "user_b":
+			if option.Type != discordgo.ApplicationCommandOptionUser {
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
+			}
+			if option.Name == "user_a" {
+				playerA = option.UserValue(nil).ID
+			} else {
+				playerB = option.UserValue(nil).ID
+			}
+		case "limit":
+			limit = int(option.IntValue())
+		default:
+			return nil, fmt.Errorf("invalid option to show head to head: %s", option.Name)
+		}
+	}
+	if playerA == "" || playerB == "" {
+		return private("Please specify two players.", nil)
+	}
+
+	return listing(c.PrintHeadToHead(playerA, playerB, limit))
+}
+
+func handleTimeline(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	playerID := i.Member.User.ID
+	limit := 10
+	for _, option := range o {
+		switch option.Name {
+		case "user":
+			if option.Type != discordgo.ApplicationCommandOptionUser {
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
+			}
+			playerID = option.UserValue(nil).ID
+		case "limit":
+			limit = int(option.IntValue())
+		default:
+			return nil, fmt.Errorf("invalid option to show timeline: %s", option.Name)
+		}
+	}
+
+	return listing(c.PrintTimeline(playerID, limit))
+}
+
+func handleChart(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	chartType := ""
+	playerID := i.Member.User.ID
+	for _, option := range o {
+		switch option.Name {
+		case "type":
+			chartType = option.StringValue()
+		case "user":
+			if option.Type != discordgo.ApplicationCommandOptionUser {
+				return nil, errors.New("internal error, unexpected option type, expected discord user")
+			}
+			playerID = option.UserValue(nil).ID
+		default:
+			return nil, fmt.Errorf("invalid option to draw chart: %s", option.Name)
+		}
+	}
+
+	var png []byte
+	var response string
+	switch chartType {
+	case "position":
+		player, err := c.PlayerStats(playerID)
+		if err != nil {
+			return nil, err
+		}
+		changes := c.PositionTimeline(playerID)
+		if len(changes) == 0 {
+			return listing(fmt.Sprintf("No position changes recorded for <@%s>", playerID), nil)
+		}
+		png, err = charts.PositionChart(fmt.Sprintf("Position of %s", player.Player.GameName), changes, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		response = fmt.Sprintf("Position history for %s/<@%s>", player.Player.GameName, playerID)
+	case "pyramid":
+		var err error
+		png, err = charts.PyramidChart("Current standings", c.PyramidTiers())
+		if err != nil {
+			return nil, err
+		}
+		response = "Current standings"
+	default:
+		return private("Please specify a valid chart type (position, pyramid)", nil)
 	}
 
-	return c.MovePlayer(playerID, position)
+	return &reply{
+		Content: response,
+		Files: []*discordgo.File{
+			{
+				Name:        chartType + ".png",
+				ContentType: "image/png",
+				Reader:      bytes.NewReader(png),
+			},
+		},
+		AllowedMentions: noMentions(),
+	}, nil
 }
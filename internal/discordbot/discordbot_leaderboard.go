@@ -0,0 +1,82 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// longest message Discord accepts
+const maxMessageLength = 2000
+
+// function that keeps the pinned leaderboard in step with the channel, it is
+// posted when turned on or when there are changes, and removed when turned off
+func (bot *DiscordBot) updateLeaderboard(log *slog.Logger, c *rankingdata.ChannelRankingData, events []rankingdata.Event) {
+	enabled, messageID := c.LeaderboardState()
+	if !enabled {
+		if messageID != "" {
+			if err := bot.Discord.ChannelMessageDelete(c.ChannelID, messageID); err != nil {
+				log.Warn("error deleting leaderboard", "error", err)
+			}
+			c.SetLeaderboardMessage("")
+		}
+		return
+	}
+	if len(events) == 0 && messageID != "" {
+		return
+	}
+
+	standings, err := c.PrintRankings()
+	if err != nil {
+		log.Error("error printing leaderboard", "error", err)
+		return
+	}
+	content := fmt.Sprintf("# Leaderboard\n%sUpdated <t:%d:R>", standings, time.Now().Unix())
+	newID, err := bot.editPinned(c.ChannelID, messageID, content)
+	if err != nil {
+		log.Error("error updating leaderboard", "error", err)
+	}
+	if newID != messageID {
+		c.SetLeaderboardMessage(newID)
+	}
+}
+
+// function that edits a pinned message, or posts and pins a new one when there
+// is none or it was deleted. It returns the ID of the message now holding the content.
+func (bot *DiscordBot) editPinned(channelID string, messageID string, content string) (string, error) {
+	if runes := []rune(content); len(runes) > maxMessageLength {
+		content = string(runes[:maxMessageLength-1]) + "…"
+	}
+	noMentions := &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
+
+	if messageID != "" {
+		_, err := bot.Discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+			ID:              messageID,
+			Channel:         channelID,
+			Content:         &content,
+			AllowedMentions: noMentions,
+		})
+		if err == nil {
+			return messageID, nil
+		}
+		// only post a new message if the old one was deleted, not on outages or missing permissions
+		var restErr *discordgo.RESTError
+		if !errors.As(err, &restErr) || restErr.Message == nil || restErr.Message.Code != discordgo.ErrCodeUnknownMessage {
+			return messageID, err
+		}
+		slog.Warn("pinned message is gone, posting a new one", "channel", channelID, "message", messageID)
+	}
+
+	message, err := bot.Discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: noMentions,
+	})
+	if err != nil {
+		return "", err
+	}
+	return message.ID, bot.Discord.ChannelMessagePin(channelID, message.ID)
+}
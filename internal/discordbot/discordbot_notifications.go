@@ -0,0 +1,139 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// how long before a challenge deadline the players are warned
+const deadlineWarning = 24 * time.Hour
+
+// function that sends direct messages to the players affected by a channel's events
+func (bot *DiscordBot) sendNotifications(log *slog.Logger, c *rankingdata.ChannelRankingData, events []rankingdata.Event) {
+	for _, event := range events {
+		switch event.Type {
+		case rankingdata.EventChallengeStarted:
+			// the thread was just made, so it is on the challenge rather than the event
+			challenge, err := c.FindChallenge(event.ChallengeID)
+			if err != nil {
+				continue
+			}
+			deadline := challenge.ChallengeDeadline.Unix()
+			bot.notify(log, c, event.OpponentID, rankingdata.NotifyChallenged,
+				fmt.Sprintf("%s challenged you [%s] in <#%s>, play before <t:%d:f> (<t:%d:R>).",
+					playerName(c, event.PlayerID), event.ChallengeID, c.ChannelID, deadline, deadline),
+				challenge.ThreadID)
+		case rankingdata.EventResultReported, rankingdata.EventChallengeCanceled:
+			// the event's player is the challenger
+			result := rankingdata.ResultHistory{Result: event.Result}
+			winner := event.OpponentID
+			if result.ChallengerWon() {
+				winner = event.PlayerID
+			}
+			reporter := playerName(c, event.ReporterID)
+			if event.Admin {
+				reporter = "An admin"
+			}
+			content := fmt.Sprintf("%s reported the result of [%s] in <#%s>: %s won (%s",
+				reporter, event.ChallengeID, c.ChannelID, playerName(c, winner), event.Result)
+			if event.Score != "" {
+				content += " " + event.Score
+			}
+			content += ")."
+			if event.Type == rankingdata.EventChallengeCanceled {
+				content = fmt.Sprintf("Challenge [%s] in <#%s> was canceled.", event.ChallengeID, c.ChannelID)
+			}
+			// players hear about results they didn't report themselves
+			for _, playerID := range []string{event.PlayerID, event.OpponentID} {
+				if event.Admin || playerID != event.ReporterID {
+					bot.notify(log, c, playerID, rankingdata.NotifyResults, content, "")
+				}
+			}
+		case rankingdata.EventPositionChanged:
+			// joining and leaving the ladder are the player's own doing
+			if event.OldPosition == 0 || event.NewPosition == 0 {
+				continue
+			}
+			bot.notify(log, c, event.PlayerID, rankingdata.NotifyPositions,
+				fmt.Sprintf("You moved from #%d to #%d in <#%s>.", event.OldPosition, event.NewPosition, c.ChannelID), "")
+		}
+	}
+}
+
+// function that warns players of challenge deadlines coming up, run by the scheduler
+func (bot *DiscordBot) warnDeadlines(now time.Time) error {
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		c, err := bot.RankingData.FindChannel(channelID)
+		if err != nil {
+			continue
+		}
+		log := slog.With("channel", channelID)
+		due := c.TakeDeadlineWarnings(now, deadlineWarning)
+		for _, challenge := range due {
+			deadline := challenge.ChallengeDeadline.Unix()
+			content := fmt.Sprintf("The deadline for [%s] %s vs %s in <#%s> is <t:%d:R>.",
+				challenge.ChallengeID,
+				playerName(c, challenge.ChallengerID), playerName(c, challenge.DefenderID),
+				c.ChannelID, deadline)
+			for _, playerID := range []string{challenge.ChallengerID, challenge.DefenderID} {
+				bot.notify(log, c, playerID, rankingdata.NotifyDeadlines, content, challengeChannel(c, challenge.ThreadID))
+			}
+		}
+		if len(due) > 0 {
+			bot.ChannelChanged(c)
+		}
+	}
+	return nil
+}
+
+// function that sends a player a direct message if they want this kind of
+// notification. Players who don't accept direct messages are mentioned in the
+// fallback channel instead, if there is one.
+func (bot *DiscordBot) notify(log *slog.Logger, c *rankingdata.ChannelRankingData, playerID string, kind string, content string, fallbackID string) {
+	if playerID == "" || !c.WantsNotification(playerID, kind) {
+		return
+	}
+	log = log.With("user", playerID, "notification", kind)
+
+	dm, err := bot.Discord.UserChannelCreate(playerID)
+	if err == nil {
+		_, err = bot.Discord.ChannelMessageSend(dm.ID, content)
+	}
+	if err == nil {
+		return
+	}
+
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) || restErr.Message == nil ||
+		restErr.Message.Code != discordgo.ErrCodeCannotSendMessagesToThisUser {
+		log.Error("error sending notification", "error", err)
+		return
+	}
+	if fallbackID == "" {
+		log.Debug("direct messages are closed, notification dropped")
+		return
+	}
+	_, err = bot.Discord.ChannelMessageSendComplex(fallbackID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("<@%s> %s", playerID, content),
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Users: []string{playerID},
+		},
+	})
+	if err != nil {
+		log.Error("error sending notification to the fallback channel", "error", err)
+	}
+}
+
+// function that returns a player's game name, or a mention if they left the ladder
+func playerName(c *rankingdata.ChannelRankingData, playerID string) string {
+	player, err := c.FindPlayer(playerID)
+	if err != nil {
+		return fmt.Sprintf("<@%s>", playerID)
+	}
+	return player.GameName
+}
@@ -0,0 +1,28 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// guild permissions that always grant admin rights in a channel
+const guildManagerPermissions = discordgo.PermissionManageChannels | discordgo.PermissionAdministrator
+
+// Discord only shows /ladmin and /lmod to members who can manage channels, the
+// bot's own admin list, admin roles and moderator roles don't change that
+const ladminAccessNote = "/ladmin and /lmod are only shown to members who can manage channels, " +
+	"allow bot admins and admin roles to use /ladmin, and moderator roles to use /lmod, " +
+	"under Server Settings > Integrations.\n"
+
+// function that checks if the member who sent an interaction has a capability
+func can(c *rankingdata.ChannelRankingData, i *discordgo.InteractionCreate, capability string) bool {
+	if c == nil || i.Member == nil {
+		return false
+	}
+	return c.HasCapability(rankingdata.Member{
+		UserID:       i.Member.User.ID,
+		RoleIDs:      i.Member.Roles,
+		GuildManager: i.Member.Permissions&guildManagerPermissions != 0,
+	}, capability)
+}
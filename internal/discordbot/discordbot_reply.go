@@ -0,0 +1,104 @@
+package discordbot
+
+import "github.com/bwmarrin/discordgo"
+
+// reply is what a command handler sends back
+type reply struct {
+	Content   string
+	Ephemeral bool // only the member who ran the command sees it
+	Embeds    []*discordgo.MessageEmbed
+	Files     []*discordgo.File
+	// who the reply may ping, nil pings everyone mentioned in the content
+	AllowedMentions *discordgo.MessageAllowedMentions
+}
+
+// function that makes a reply everyone in the channel sees
+func public(content string, err error) (*reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &reply{Content: content}, nil
+}
+
+// function that makes a reply everyone sees but that doesn't ping anyone, for
+// standings and other listings that mention lots of players
+func listing(content string, err error) (*reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &reply{Content: content, AllowedMentions: noMentions()}, nil
+}
+
+// function that makes a reply only the member who ran the command sees, for
+// permission denials, usage hints and personal views
+func private(content string, err error) (*reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &reply{Content: content, Ephemeral: true, AllowedMentions: noMentions()}, nil
+}
+
+// function that makes the reply to an error, only the member who ran the command sees it
+func errorReply(err error) *reply {
+	return &reply{Content: err.Error(), Ephemeral: true, AllowedMentions: noMentions()}
+}
+
+// function that returns allowed mentions that don't ping anyone
+func noMentions() *discordgo.MessageAllowedMentions {
+	return &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
+}
+
+// function that builds the interaction response data for a reply
+func (r *reply) data() *discordgo.InteractionResponseData {
+	data := &discordgo.InteractionResponseData{
+		Content:         r.Content,
+		Embeds:          r.Embeds,
+		Files:           r.Files,
+		AllowedMentions: r.AllowedMentions,
+	}
+	if r.Ephemeral {
+		data.Flags = discordgo.MessageFlagsEphemeral
+	}
+	return data
+}
+
+// function that responds to an interaction with a reply
+func respond(s *discordgo.Session, i *discordgo.InteractionCreate, r *reply) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: r.data(),
+	})
+}
+
+// function that tells Discord a reply is on its way, for commands that may take
+// longer than the 3 seconds Discord waits for a response
+func deferResponse(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+}
+
+// function that replaces a deferred response with a reply. The deferred
+// response is public, so ephemeral replies are sent as a follow-up instead.
+func respondDeferred(s *discordgo.Session, i *discordgo.InteractionCreate, r *reply) error {
+	if r.Ephemeral {
+		if err := s.InteractionResponseDelete(i.Interaction); err != nil {
+			return err
+		}
+		_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+			Content:         r.Content,
+			Embeds:          r.Embeds,
+			Files:           r.Files,
+			AllowedMentions: r.AllowedMentions,
+			Flags:           discordgo.MessageFlagsEphemeral,
+		})
+		return err
+	}
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:         &r.Content,
+		Embeds:          &r.Embeds,
+		Files:           r.Files,
+		AllowedMentions: r.AllowedMentions,
+	})
+	return err
+}
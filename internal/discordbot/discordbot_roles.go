@@ -0,0 +1,314 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// roleSyncer applies rank roles in the background. Requests are collected for
+// a short while so a burst of changes is synced once, and role edits are paced
+// to stay clear of Discord's rate limits (discordgo waits out any that are hit).
+type roleSyncer struct {
+	bot     *DiscordBot
+	delay   time.Duration // how long to collect requests before syncing
+	pace    time.Duration // pause between role edits
+	mutex   sync.Mutex
+	pending map[string]bool // channel IDs whose guild needs syncing
+	wake    chan struct{}
+	done    chan struct{}
+	wait    sync.WaitGroup
+}
+
+func newRoleSyncer(bot *DiscordBot) *roleSyncer {
+	return &roleSyncer{
+		bot:     bot,
+		delay:   5 * time.Second,
+		pace:    250 * time.Millisecond,
+		pending: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// function that asks for the rank roles of a channel's guild to be synced
+func (syncer *roleSyncer) queue(channelID string) {
+	syncer.mutex.Lock()
+	syncer.pending[channelID] = true
+	syncer.mutex.Unlock()
+
+	select {
+	case syncer.wake <- struct{}{}:
+	default:
+	}
+}
+
+// function that syncs queued channels until stopped
+func (syncer *roleSyncer) start() {
+	syncer.wait.Add(1)
+	go func() {
+		defer syncer.wait.Done()
+		for {
+			select {
+			case <-syncer.wake:
+			case <-syncer.done:
+				return
+			}
+
+			// let more requests arrive before syncing
+			select {
+			case <-time.After(syncer.delay):
+			case <-syncer.done:
+				return
+			}
+
+			syncer.mutex.Lock()
+			pending := syncer.pending
+			syncer.pending = map[string]bool{}
+			syncer.mutex.Unlock()
+
+			// ladders in the same guild are synced together
+			guilds := map[string]bool{}
+			for channelID := range pending {
+				guildID, err := syncer.bot.guildID(channelID)
+				if err != nil {
+					slog.Error("error finding the guild to sync rank roles", "channel", channelID, "error", err)
+					continue
+				}
+				guilds[guildID] = true
+			}
+			for guildID := range guilds {
+				if err := syncer.sync(guildID); err != nil {
+					slog.Error("error syncing rank roles", "guild", guildID, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+func (syncer *roleSyncer) stop() {
+	close(syncer.done)
+	syncer.wait.Wait()
+}
+
+// function that gives and takes rank roles so a guild's members match its
+// ladders. The roles every ladder in the guild wants are combined, so a member
+// keeps a role one ladder gives even if another doesn't, and a role is only
+// ever taken from members the bot gave it to.
+func (syncer *roleSyncer) sync(guildID string) error {
+	bot := syncer.bot
+
+	// the ladder that hands out each role, or handed it out before it was retired
+	owners := map[string]*rankingdata.ChannelRankingData{}
+	desired := map[string][]string{}
+	ladders := []*rankingdata.ChannelRankingData{}
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		if id, err := bot.guildID(channelID); err != nil || id != guildID {
+			continue
+		}
+		c, err := bot.RankingData.FindChannel(channelID)
+		if err != nil {
+			continue
+		}
+		ladders = append(ladders, c)
+		for _, roleID := range c.GrantedRoleIDs() {
+			owners[roleID] = c
+		}
+	}
+	for _, c := range ladders {
+		for _, role := range c.GetRankRoles() {
+			owners[role.RoleID] = c
+		}
+		for userID, roleIDs := range c.DesiredRankRoles() {
+			desired[userID] = append(desired[userID], roleIDs...)
+		}
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	// walk every member of the guild, so roles are taken from players who left the ladder too
+	added, removed, forgotten := 0, 0, 0
+	err := bot.forEachMember(guildID, func(member *discordgo.Member) {
+		userID := member.User.ID
+		for roleID, owner := range owners {
+			want := slices.Contains(desired[userID], roleID)
+			has := slices.Contains(member.Roles, roleID)
+			granted := owner.HasGranted(roleID, userID)
+			var err error
+			switch {
+			case want && !has:
+				if err = bot.Discord.GuildMemberRoleAdd(guildID, userID, roleID); err == nil {
+					owner.RecordGrant(roleID, userID, true)
+					added++
+				}
+			case !want && has && granted:
+				if err = bot.Discord.GuildMemberRoleRemove(guildID, userID, roleID); err == nil {
+					owner.RecordGrant(roleID, userID, false)
+					removed++
+				}
+			case !want && !has && granted:
+				// someone already took the role away
+				owner.RecordGrant(roleID, userID, false)
+				forgotten++
+				continue
+			default:
+				continue
+			}
+			if err != nil {
+				slog.Error("error updating rank role", "guild", guildID, "user", userID, "role", roleID, "error", err)
+			}
+			time.Sleep(syncer.pace)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// save who was given which role
+	if added+removed+forgotten > 0 {
+		bot.ChannelChanged(nil)
+	}
+	slog.Info("synced rank roles", "guild", guildID, "added", added, "removed", removed)
+	return nil
+}
+
+// function that calls visit for every member of a guild
+func (bot *DiscordBot) forEachMember(guildID string, visit func(*discordgo.Member)) error {
+	after := ""
+	for {
+		members, err := bot.Discord.GuildMembers(guildID, after, 1000)
+		if err != nil {
+			return fmt.Errorf("listing members (is the server members intent enabled?): %w", err)
+		}
+		for _, member := range members {
+			visit(member)
+		}
+		if len(members) < 1000 {
+			return nil
+		}
+		after = members[len(members)-1].User.ID
+	}
+}
+
+// function that checks a role can be used as a rank role of a channel. The bot
+// takes rank roles away when players drop out of range, so the role must not
+// belong to another ladder or be held by anyone who isn't a player.
+func (bot *DiscordBot) checkRankRole(c *rankingdata.ChannelRankingData, roleID string) error {
+	if channelID, ok := bot.RankingData.RankRoleChannel(roleID); ok {
+		if channelID != c.ChannelID {
+			return fmt.Errorf("<@&%s> is already a rank role in <#%s>", roleID, channelID)
+		}
+		// changing the positions of one of this ladder's roles
+		return nil
+	}
+
+	guildID, err := bot.guildID(c.ChannelID)
+	if err != nil {
+		return err
+	}
+	holders := 0
+	err = bot.forEachMember(guildID, func(member *discordgo.Member) {
+		if slices.Contains(member.Roles, roleID) && !c.IsPlayer(member.User.ID) {
+			holders++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return fmt.Errorf("<@&%s> is held by %d members who aren't players in this ladder, use a role only the ladder hands out", roleID, holders)
+	}
+	return nil
+}
+
+// function that checks if any of a channel's events could change its rank roles
+func changesRanks(events []rankingdata.Event) bool {
+	for _, event := range events {
+		switch event.Type {
+		case rankingdata.EventPositionChanged, rankingdata.EventPlayerRegistered, rankingdata.EventPlayerUnregistered:
+			return true
+		}
+	}
+	return false
+}
+
+// function that parses positions like "1" or "1-10"
+func parsePositions(positions string) (int, int, error) {
+	first, last, isRange := strings.Cut(positions, "-")
+	minPosition, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, errors.New("invalid positions, must be like 1 or 1-10")
+	}
+	if !isRange {
+		return minPosition, minPosition, nil
+	}
+	maxPosition, err := strconv.Atoi(strings.TrimSpace(last))
+	if err != nil {
+		return 0, 0, errors.New("invalid positions, must be like 1 or 1-10")
+	}
+	return minPosition, maxPosition, nil
+}
+
+func (bot *DiscordBot) handleRankRole(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption,
+	entry *rankingdata.AuditEntry) (*reply, error) {
+
+	if !can(c, i, rankingdata.CapEditSettings) {
+		return private("You must be an admin to set rank roles.", nil)
+	}
+
+	role := rankingdata.RankRole{}
+	remove := false
+	for _, option := range o {
+		switch option.Name {
+		case "role":
+			role.RoleID = option.RoleValue(nil, "").ID
+		case "positions":
+			var err error
+			role.MinPosition, role.MaxPosition, err = parsePositions(option.StringValue())
+			if err != nil {
+				return nil, err
+			}
+		case "tier":
+			role.Tier = int(option.IntValue())
+		case "remove":
+			remove = option.BoolValue()
+		default:
+			return nil, fmt.Errorf("invalid option to set rank roles: %s", option.Name)
+		}
+	}
+
+	before := c.PrintRankRoles()
+	if remove {
+		if err := c.RemoveRankRole(role.RoleID); err != nil {
+			return nil, err
+		}
+	} else if role.Tier == 0 && role.MinPosition == 0 {
+		return private("Please specify positions or a tier for the role.", nil)
+	} else if err := bot.checkRankRole(c, role.RoleID); err != nil {
+		return nil, err
+	} else if err := c.SetRankRole(role); err != nil {
+		return nil, err
+	}
+
+	entry.Action = "rank_role"
+	entry.Before = before
+	entry.After = c.PrintRankRoles()
+
+	// take the role away from the players it was given to when it is removed, or give it out
+	bot.roleSync.queue(c.ChannelID)
+	if remove {
+		return listing(fmt.Sprintf("Removed rank role <@&%s>, it will be taken from players shortly\n%s", role.RoleID, entry.After), nil)
+	}
+	return listing(fmt.Sprintf("Rank roles will be updated shortly\n%s", entry.After), nil)
+}
@@ -0,0 +1,218 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the container image has no zoneinfo
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// custom IDs of the accept buttons look like schedule:<channel>:<challenge>:<unix time>,
+// so a button from an older proposal can't book a time that is no longer proposed
+const scheduleButtonPrefix = "schedule"
+
+// layout of the times given to /schedule
+const scheduleTimeLayout = "2006-01-02 15:04"
+
+// how long before a scheduled match the players are reminded
+const reminderLead = 30 * time.Minute
+
+// how long a guild event for a match lasts, Discord needs an end time
+const matchEventLength = time.Hour
+
+func handleSchedule(c *rankingdata.ChannelRankingData,
+	i *discordgo.InteractionCreate,
+	o []*discordgo.ApplicationCommandInteractionDataOption) (*reply, error) {
+
+	times := ""
+	timezone := "UTC"
+	challengeID := ""
+	for _, option := range o {
+		switch option.Name {
+		case "times":
+			times = option.StringValue()
+		case "timezone":
+			timezone = option.StringValue()
+		case "challenge":
+			challengeID = option.StringValue()
+		default:
+			return nil, errors.New("invalid option to schedule a challenge: " + option.Name)
+		}
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %s, use a name like Europe/Berlin", timezone)
+	}
+	proposed := []time.Time{}
+	for _, value := range strings.Split(times, ",") {
+		t, err := time.ParseInLocation(scheduleTimeLayout, strings.TrimSpace(value), location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, use YYYY-MM-DD HH:MM", strings.TrimSpace(value))
+		}
+		proposed = append(proposed, t)
+	}
+	return public(c.ProposeTimes(i.Member.User.ID, challengeID, proposed))
+}
+
+// function that accepts a proposed time from a button click
+func acceptFromButton(c *rankingdata.ChannelRankingData, userID string, challengeID string, arg string) (string, error) {
+	seconds, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid proposed time")
+	}
+	return c.AcceptTime(userID, challengeID, time.Unix(seconds, 0))
+}
+
+// function that posts accept buttons for proposed times and keeps guild events
+// in step with scheduled, finished and canceled matches
+func (bot *DiscordBot) updateSchedules(log *slog.Logger, c *rankingdata.ChannelRankingData, events []rankingdata.Event) {
+	for _, event := range events {
+		var err error
+		switch event.Type {
+		case rankingdata.EventTimesProposed:
+			err = bot.postProposedTimes(c, event)
+		case rankingdata.EventMatchScheduled:
+			if c.HasScheduledEvents() {
+				err = bot.saveMatchEvent(c, event)
+			}
+		case rankingdata.EventResultReported, rankingdata.EventChallengeCanceled:
+			if event.GuildEventID != "" {
+				err = bot.deleteMatchEvent(c.ChannelID, event.GuildEventID)
+			}
+		}
+		if err != nil {
+			log.Error("error updating match schedule", "challenge", event.ChallengeID, "error", err)
+		}
+	}
+}
+
+// function that asks the other player to pick one of the proposed times
+func (bot *DiscordBot) postProposedTimes(c *rankingdata.ChannelRankingData, event rankingdata.Event) error {
+	challenge, err := c.FindChallenge(event.ChallengeID)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("<@%s>, <@%s> proposed times for [%s], pick one:\n",
+		event.OpponentID, event.PlayerID, event.ChallengeID)
+	buttons := []discordgo.MessageComponent{}
+	for index, t := range challenge.ProposedTimes {
+		content += fmt.Sprintf("  %d. <t:%d:f> (<t:%d:R>)\n", index+1, t.Unix(), t.Unix())
+		buttons = append(buttons, discordgo.Button{
+			Label:    strconv.Itoa(index + 1),
+			Style:    discordgo.PrimaryButton,
+			CustomID: strings.Join([]string{scheduleButtonPrefix, c.ChannelID, event.ChallengeID, strconv.FormatInt(t.Unix(), 10)}, ":"),
+		})
+	}
+
+	_, err = bot.Discord.ChannelMessageSendComplex(challengeChannel(c, event.ThreadID), &discordgo.MessageSend{
+		Content:    content,
+		Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}},
+	})
+	return err
+}
+
+// function that creates or moves the guild event for a scheduled match
+func (bot *DiscordBot) saveMatchEvent(c *rankingdata.ChannelRankingData, event rankingdata.Event) error {
+	challenge, err := c.FindChallenge(event.ChallengeID)
+	if err != nil {
+		return err
+	}
+	guildID, err := bot.guildID(c.ChannelID)
+	if err != nil {
+		return err
+	}
+	challenger, err := c.FindPlayer(challenge.ChallengerID)
+	if err != nil {
+		return err
+	}
+	defender, err := c.FindPlayer(challenge.DefenderID)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("[%s] %s vs %s", challenge.ChallengeID, challenger.GameName, defender.GameName)
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	start := challenge.ScheduledTime
+	end := start.Add(matchEventLength)
+	params := &discordgo.GuildScheduledEventParams{
+		Name:               name,
+		Description:        "Ladder match",
+		ScheduledStartTime: &start,
+		ScheduledEndTime:   &end,
+		PrivacyLevel:       discordgo.GuildScheduledEventPrivacyLevelGuildOnly,
+		EntityType:         discordgo.GuildScheduledEventEntityTypeExternal,
+		EntityMetadata:     &discordgo.GuildScheduledEventEntityMetadata{Location: "Ladder"},
+	}
+
+	if event.GuildEventID != "" {
+		_, err = bot.Discord.GuildScheduledEventEdit(guildID, event.GuildEventID, params)
+		return err
+	}
+	guildEvent, err := bot.Discord.GuildScheduledEventCreate(guildID, params)
+	if err != nil {
+		return err
+	}
+	return c.SetChallengeGuildEvent(challenge.ChallengeID, guildEvent.ID)
+}
+
+// function that removes the guild event of a match that won't be played as scheduled
+func (bot *DiscordBot) deleteMatchEvent(channelID string, eventID string) error {
+	guildID, err := bot.guildID(channelID)
+	if err != nil {
+		return err
+	}
+	return bot.Discord.GuildScheduledEventDelete(guildID, eventID)
+}
+
+// function that reminds players of matches starting soon, run by the scheduler
+func (bot *DiscordBot) sendReminders(now time.Time) error {
+	for _, channelID := range bot.RankingData.ChannelIDs() {
+		c, err := bot.RankingData.FindChannel(channelID)
+		if err != nil {
+			continue
+		}
+		due := c.TakeDueReminders(now, reminderLead)
+		for _, challenge := range due {
+			start := challenge.ScheduledTime.Unix()
+			_, err := bot.Discord.ChannelMessageSend(challengeChannel(c, challenge.ThreadID),
+				fmt.Sprintf("Reminder: <@%s> vs <@%s> [%s] starts <t:%d:R>.",
+					challenge.ChallengerID, challenge.DefenderID, challenge.ChallengeID, start))
+			if err != nil {
+				slog.Error("error sending match reminder", "channel", channelID, "challenge", challenge.ChallengeID, "error", err)
+			}
+		}
+		if len(due) > 0 {
+			bot.ChannelChanged(c)
+		}
+	}
+	return nil
+}
+
+// function that returns the guild a channel belongs to
+func (bot *DiscordBot) guildID(channelID string) (string, error) {
+	channel, err := bot.Discord.State.Channel(channelID)
+	if err != nil {
+		if channel, err = bot.Discord.Channel(channelID); err != nil {
+			return "", err
+		}
+	}
+	return channel.GuildID, nil
+}
+
+// function that returns where to talk to the players of a challenge, its thread if it has one
+func challengeChannel(c *rankingdata.ChannelRankingData, threadID string) string {
+	if threadID != "" {
+		return threadID
+	}
+	return c.ChannelID
+}
@@ -0,0 +1,69 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/metrics"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// scheduledJob is background work the scheduler runs on every tick
+type scheduledJob struct {
+	name string
+	run  func(now time.Time) error
+}
+
+// scheduler runs jobs like match reminders on a fixed interval
+type scheduler struct {
+	interval time.Duration
+	jobs     []scheduledJob
+	done     chan struct{}
+	wait     sync.WaitGroup
+}
+
+func newScheduler(interval time.Duration) *scheduler {
+	return &scheduler{
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// function that adds a job, jobs must be added before the scheduler is started
+func (sched *scheduler) add(name string, run func(now time.Time) error) {
+	sched.jobs = append(sched.jobs, scheduledJob{name: name, run: run})
+}
+
+// function that runs every job once, counting and logging the outcomes
+func (sched *scheduler) runJobs(now time.Time) {
+	for _, job := range sched.jobs {
+		outcome := "ok"
+		if err := job.run(now); err != nil {
+			outcome = "error"
+			slog.Error("scheduled job failed", "job", job.name, "error", err)
+		}
+		metrics.SchedulerRuns.WithLabelValues(job.name, outcome).Inc()
+	}
+}
+
+// function that runs the jobs on every tick until stopped
+func (sched *scheduler) start() {
+	sched.wait.Add(1)
+	go func() {
+		defer sched.wait.Done()
+		ticker := time.NewTicker(sched.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				sched.runJobs(now)
+			case <-sched.done:
+				return
+			}
+		}
+	}()
+}
+
+func (sched *scheduler) stop() {
+	close(sched.done)
+	sched.wait.Wait()
+}
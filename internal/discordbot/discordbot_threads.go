@@ -0,0 +1,119 @@
+package discordbot
+
+import (
+	"discord_ladder_bot/internal/rankingdata"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// custom IDs of the result buttons look like result:<channel>:<challenge>:<action>
+const resultButtonPrefix = "result"
+
+// function that makes and archives challenge threads for a channel's events
+func (bot *DiscordBot) updateThreads(log *slog.Logger, c *rankingdata.ChannelRankingData, events []rankingdata.Event) {
+	for _, event := range events {
+		var err error
+		switch event.Type {
+		case rankingdata.EventChallengeStarted:
+			err = bot.createChallengeThread(log, c, event.ChallengeID)
+		case rankingdata.EventResultReported, rankingdata.EventChallengeCanceled:
+			if event.ThreadID != "" {
+				err = bot.archiveChallengeThread(event.ThreadID)
+			}
+		}
+		if err != nil {
+			log.Error("error updating challenge thread", "challenge", event.ChallengeID, "error", err)
+		}
+	}
+}
+
+// function that opens a private thread for the players in a challenge
+func (bot *DiscordBot) createChallengeThread(log *slog.Logger, c *rankingdata.ChannelRankingData, challengeID string) error {
+	challenge, err := c.FindChallenge(challengeID)
+	if err != nil {
+		return err
+	}
+	challenger, err := c.FindPlayer(challenge.ChallengerID)
+	if err != nil {
+		return err
+	}
+	defender, err := c.FindPlayer(challenge.DefenderID)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("[%s] %s vs %s", challengeID, challenger.GameName, defender.GameName)
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	thread, err := bot.Discord.ThreadStartComplex(c.ChannelID, &discordgo.ThreadStart{
+		Name:                name,
+		Type:                discordgo.ChannelTypeGuildPrivateThread,
+		AutoArchiveDuration: 10080, // a week, the longest Discord allows
+		Invitable:           false,
+	})
+	if err != nil {
+		return err
+	}
+	// remember the thread at once so it is archived with the challenge, even if the rest fails
+	if err := c.SetChallengeThread(challengeID, thread.ID); err != nil {
+		return err
+	}
+	for _, playerID := range []string{challenger.PlayerID, defender.PlayerID} {
+		if err := bot.Discord.ThreadMemberAdd(thread.ID, playerID); err != nil {
+			log.Error("error adding player to challenge thread", "challenge", challengeID, "user", playerID, "error", err)
+		}
+	}
+
+	deadline := challenge.ChallengeDeadline.Unix()
+	_, err = bot.Discord.ChannelMessageSendComplex(thread.ID, &discordgo.MessageSend{
+		Content: fmt.Sprintf("%s/<@%s> has challenged %s/<@%s>, play before <t:%d:f> (<t:%d:R>).\n"+
+			"The defender reports the result or forfeits, the challenger can cancel.",
+			challenger.GameName, challenger.PlayerID,
+			defender.GameName, defender.PlayerID,
+			deadline, deadline),
+		Components: resultButtons(c.ChannelID, challengeID),
+	})
+	return err
+}
+
+// function that archives and locks a challenge thread once the match is over
+func (bot *DiscordBot) archiveChallengeThread(threadID string) error {
+	archived := true
+	locked := true
+	_, err := bot.Discord.ChannelEdit(threadID, &discordgo.ChannelEdit{
+		Archived: &archived,
+		Locked:   &locked,
+	})
+	return err
+}
+
+// function that builds the result buttons for a challenge
+func resultButtons(channelID string, challengeID string) []discordgo.MessageComponent {
+	button := func(label string, action string, style discordgo.ButtonStyle) discordgo.Button {
+		return discordgo.Button{
+			Label:    label,
+			Style:    style,
+			CustomID: strings.Join([]string{resultButtonPrefix, channelID, challengeID, action}, ":"),
+		}
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				button("Defender won", "won", discordgo.PrimaryButton),
+				button("Challenger won", "lost", discordgo.PrimaryButton),
+				button("Forfeit", "forfeit", discordgo.SecondaryButton),
+				button("Cancel challenge", "cancel", discordgo.DangerButton),
+			},
+		},
+	}
+}
+
+// function that reports a result from a button click, the clicker reports so
+// only the players in the challenge can use the buttons
+func resolveFromButton(c *rankingdata.ChannelRankingData, userID string, challengeID string, action string) (string, error) {
+	return c.ResolveChallenge(userID, challengeID, action, "")
+}
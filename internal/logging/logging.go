@@ -0,0 +1,31 @@
+// Package logging sets up the structured logger used across the bot.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"discord_ladder_bot/internal/config"
+)
+
+// NewLogger creates a logger with the level and format from the config
+func NewLogger(conf *config.Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if conf.LogLevel != "" {
+		if err := level.UnmarshalText([]byte(conf.LogLevel)); err != nil {
+			return nil, fmt.Errorf("invalid log_level %q, must be debug, info, warn or error", conf.LogLevel)
+		}
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(conf.LogFormat) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log_format %q, must be text or json", conf.LogFormat)
+	}
+}
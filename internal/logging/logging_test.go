@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"discord_ladder_bot/internal/config"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&config.Config{LogLevel: "warn", LogFormat: "json"}, &buffer)
+	if err != nil {
+		t.Fatalf("Error creating logger: %s", err)
+	}
+
+	// lines below the level are dropped
+	logger.Info("hidden")
+	assert.Equal(t, buffer.Len(), 0)
+
+	logger.With("command", "challenge").Warn("shown", "interaction", "42")
+	var line map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &line); err != nil {
+		t.Fatalf("Error decoding log line: %s", err)
+	}
+	assert.Equal(t, line["msg"], "shown")
+	assert.Equal(t, line["command"], "challenge")
+	assert.Equal(t, line["interaction"], "42")
+
+	// text is the default
+	buffer.Reset()
+	logger, _ = NewLogger(&config.Config{}, &buffer)
+	logger.Info("hello", "guild", "1")
+	assert.Equal(t, strings.Contains(buffer.String(), "level=INFO msg=hello guild=1"), true)
+
+	_, err = NewLogger(&config.Config{LogLevel: "loud"}, &buffer)
+	assert.Equal(t, err != nil, true)
+	_, err = NewLogger(&config.Config{LogFormat: "xml"}, &buffer)
+	assert.Equal(t, err != nil, true)
+}
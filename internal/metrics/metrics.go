@@ -0,0 +1,75 @@
+// Package metrics defines the bot's Prometheus metrics and serves them for
+// scraping.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// registry holds the bot's metrics and the Go runtime and process metrics
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+// the metrics exported by the bot
+var (
+	Commands = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladder_commands_total",
+		Help: "Slash commands handled, by command and outcome.",
+	}, []string{"command", "outcome"})
+	CommandDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "ladder_command_duration_seconds",
+		Help: "Time spent handling slash commands.",
+	}, []string{"command"})
+	StorageDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "ladder_storage_duration_seconds",
+		Help: "Time spent reading or writing the ranking data.",
+	}, []string{"operation"})
+	StorageFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladder_storage_failures_total",
+		Help: "Failed reads or writes of the ranking data.",
+	}, []string{"operation"})
+	ActiveChallenges = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ladder_active_challenges",
+		Help: "Active challenges in each ladder channel.",
+	}, []string{"channel"})
+	GatewayReconnects = factory.NewCounter(prometheus.CounterOpts{
+		Name: "ladder_gateway_reconnects_total",
+		Help: "Times the Discord gateway connection was re-established.",
+	})
+	SchedulerRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "ladder_scheduler_runs_total",
+		Help: "Background scheduler job runs, by job and outcome.",
+	}, []string{"job", "outcome"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// function that records the seconds elapsed since start in a histogram
+func Since(histogram *prometheus.HistogramVec, start time.Time, labelValues ...string) {
+	histogram.WithLabelValues(labelValues...).Observe(time.Since(start).Seconds())
+}
+
+// function that records the latency and outcome of a storage operation
+func ObserveStorage(operation string, start time.Time, err error) {
+	Since(StorageDuration, start, operation)
+	if err != nil {
+		StorageFailures.WithLabelValues(operation).Inc()
+	}
+}
+
+// Handler serves the metrics for Prometheus to scrape
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
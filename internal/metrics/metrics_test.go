@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	Commands.WithLabelValues("register", "ok").Inc()
+	Commands.WithLabelValues("register", "ok").Inc()
+	assert.Equal(t, testutil.ToFloat64(Commands.WithLabelValues("register", "ok")), 2.0)
+
+	ObserveStorage("write", time.Now(), errors.New("unreachable"))
+	ObserveStorage("write", time.Now(), nil)
+	assert.Equal(t, testutil.ToFloat64(StorageFailures.WithLabelValues("write")), 1.0)
+
+	ActiveChallenges.WithLabelValues("1234").Set(7)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, line := range []string{
+		"# TYPE ladder_commands_total counter",
+		`ladder_commands_total{command="register",outcome="ok"} 2`,
+		`ladder_active_challenges{channel="1234"} 7`,
+		"# TYPE ladder_storage_duration_seconds histogram",
+		`ladder_storage_duration_seconds_count{operation="write"} 2`,
+	} {
+		assert.Equal(t, strings.Contains(body, line+"\n"), true, line)
+	}
+
+	// reset drops series that are no longer set
+	ActiveChallenges.Reset()
+	recorder = httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, strings.Contains(recorder.Body.String(), `ladder_active_challenges{channel="1234"}`), false)
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/metrics"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -18,43 +22,118 @@ import (
 type RankingData struct {
 	Version  string                `bson:"version,omitempty"`
 	Channels []*ChannelRankingData `bson:"channels"`
-	conf     *config.Config
-	mutex    sync.Mutex
+	AuditLog []AuditEntry          `bson:"-"`
+	// AuditHook is called with every recorded audit entry, e.g. to mirror it to a log channel
+	AuditHook    func(AuditEntry) `bson:"-"`
+	conf         *config.Config
+	mutex        sync.Mutex
+	pendingAudit []AuditEntry
+	auditMutex   sync.Mutex
 }
 
 type ChannelRankingData struct {
-	ChannelID            string          `bson:"channel_id"`
-	ChallengeMode        string          `bson:"challenge_mode"`
-	ChallengeTimeoutDays time.Duration   `bson:"challenge_timeout_days"`
-	RankedPlayers        []Player        `bson:"ranked_players"`
-	ActiveChallenges     []Challenge     `bson:"active_challenges"`
-	ResultHistory        []ResultHistory `bson:"result_history"`
-	Admins               []string        `bson:"admins"`
-	Notes                string          `bson:"notes,omitempty"`
+	ChannelID            string              `bson:"channel_id"`
+	ChallengeMode        string              `bson:"challenge_mode"`
+	ChallengeTimeoutDays time.Duration       `bson:"challenge_timeout_days"`
+	RematchCooldown      time.Duration       `bson:"rematch_cooldown,omitempty"`
+	DefenseImmunity      time.Duration       `bson:"defense_immunity,omitempty"`
+	MaxPositionsUp       int                 `bson:"max_positions_up,omitempty"`
+	MaxTiersUp           int                 `bson:"max_tiers_up,omitempty"`
+	MaxPercentUp         int                 `bson:"max_percent_up,omitempty"`
+	SkipInactive         bool                `bson:"skip_inactive,omitempty"`
+	AllowDownward        bool                `bson:"allow_downward,omitempty"`
+	MaxOutgoing          int                 `bson:"max_outgoing_challenges,omitempty"`
+	MaxIncoming          int                 `bson:"max_incoming_challenges,omitempty"`
+	LastChallengeID      int                 `bson:"last_challenge_id"`
+	RankedPlayers        []Player            `bson:"ranked_players"`
+	ActiveChallenges     []Challenge         `bson:"active_challenges"`
+	ResultHistory        []ResultHistory     `bson:"result_history"`
+	PositionHistory      []PositionChange    `bson:"position_history"`
+	Admins               []string            `bson:"admins"`
+	AdminRoles           []string            `bson:"admin_roles,omitempty"`
+	ModeratorRoles       []string            `bson:"moderator_roles,omitempty"`
+	OpenAdmin            bool                `bson:"open_admin,omitempty"`      // everyone has admin rights
+	AdminsMigrated       bool                `bson:"admins_migrated,omitempty"` // see migrateOpenAdmin
+	RankRoles            []RankRole          `bson:"rank_roles,omitempty"`
+	GrantedRoles         map[string][]string `bson:"granted_roles,omitempty"`    // role ID to the members the bot gave it to
+	ScheduledEvents      bool                `bson:"scheduled_events,omitempty"` // add agreed match times as guild events
+	Digest               *Digest             `bson:"digest,omitempty"`
+	Leaderboard          bool                `bson:"leaderboard,omitempty"` // keep a pinned leaderboard up to date
+	LeaderboardMessageID string              `bson:"leaderboard_message_id,omitempty"`
+	Notes                string              `bson:"notes,omitempty"`
 	mutex                sync.Mutex
+	events               []Event
 }
 
 type Player struct {
-	PlayerID string `bson:"player_id"`
-	Position int    `bson:"position"`
-	Status   string `bson:"status,omitempty"`
-	GameName string `bson:"game_name,omitempty"`
-	Notes    string `bson:"notes,omitempty"`
+	PlayerID     string `bson:"player_id"`
+	Position     int    `bson:"position"`
+	BestPosition int    `bson:"best_position,omitempty"`
+	Status       string `bson:"status,omitempty"`
+	GameName     string `bson:"game_name,omitempty"`
+	Notes        string `bson:"notes,omitempty"`
+	// kinds of direct message notifications the player turned off
+	MutedNotifications []string `bson:"muted_notifications,omitempty"`
 }
 
 type Challenge struct {
-	ChallengerID      string    `bson:"challenger_id"`
-	DefenderID        string    `bson:"challengee_id"`
-	ChallengeDate     time.Time `bson:"challenge_date"`
-	ChallengeDeadline time.Time `bson:"challenge_deadline"`
+	ChallengeID       string      `bson:"challenge_id"`
+	ChallengerID      string      `bson:"challenger_id"`
+	DefenderID        string      `bson:"challengee_id"`
+	ChallengeDate     time.Time   `bson:"challenge_date"`
+	ChallengeDeadline time.Time   `bson:"challenge_deadline"`
+	ThreadID          string      `bson:"thread_id,omitempty"` // private thread for the players, if one was made
+	ProposedTimes     []time.Time `bson:"proposed_times,omitempty"`
+	ProposedBy        string      `bson:"proposed_by,omitempty"`
+	ScheduledTime     time.Time   `bson:"scheduled_time,omitempty"` // the time both players agreed on
+	GuildEventID      string      `bson:"guild_event_id,omitempty"`
+	ReminderSent      bool        `bson:"reminder_sent,omitempty"`
+	DeadlineWarned    bool        `bson:"deadline_warned,omitempty"`
 }
 
 type ResultHistory struct {
-	ChallengerID  string    `bson:"challenger_id"`
-	DefenderID    string    `bson:"challengee_id"`
-	Result        string    `bson:"result"`
-	ChallengeDate time.Time `bson:"challenge_date,omitempty"`
-	ResolveDate   time.Time `bson:"resolve_date,omitempty"`
+	ChallengeID  string `bson:"challenge_id,omitempty"`
+	ChallengerID string `bson:"challenger_id"`
+	DefenderID   string `bson:"challengee_id"`
+	// positions before the match was resolved
+	ChallengerPosition int       `bson:"challenger_position,omitempty"`
+	DefenderPosition   int       `bson:"challengee_position,omitempty"`
+	Result             string    `bson:"result"`
+	Score              string    `bson:"score,omitempty"`
+	ReporterID         string    `bson:"reporter_id,omitempty"`
+	Admin              bool      `bson:"admin,omitempty"` // the reporter is an admin, not one of the players
+	ChallengeDate      time.Time `bson:"challenge_date,omitempty"`
+	ChallengeDeadline  time.Time `bson:"challenge_deadline,omitempty"`
+	ResolveDate        time.Time `bson:"resolve_date,omitempty"`
+}
+
+// PositionChange records a player moving on the ladder
+type PositionChange struct {
+	PlayerID    string    `bson:"player_id"`
+	OldPosition int       `bson:"old_position"` // 0 when the player joined the ladder
+	NewPosition int       `bson:"new_position"` // 0 when the player left the ladder
+	Date        time.Time `bson:"date"`
+	Reason      string    `bson:"reason"`
+	ChallengeID string    `bson:"challenge_id,omitempty"`
+}
+
+// PlayerStats is a summary of a player's record computed from the result history
+type PlayerStats struct {
+	Player           Player
+	Tier             int
+	BestPosition     int
+	ChallengerWins   int
+	ChallengerLosses int
+	DefenderWins     int
+	DefenderLosses   int
+	DefenderForfeits int
+	Streak           int // positive for a winning streak, negative for a losing streak
+	LastMatch        time.Time
+}
+
+// function that determines if the challenger won a match
+func (result *ResultHistory) ChallengerWon() bool {
+	return result.Result == "lost" || result.Result == "forfeit" || result.Result == "timed out"
 }
 
 // Locks the ranking data for a channel
@@ -69,6 +148,14 @@ func (c *ChannelRankingData) Unlock() {
 
 // function that reads a mongodb and returns a RankingData struct
 func ReadRankingData(conf *config.Config) (*RankingData, error) {
+	start := time.Now()
+	rankingData, err := readRankingData(conf)
+	metrics.ObserveStorage("read", start, err)
+	return rankingData, err
+}
+
+// private function that reads the ranking data, see ReadRankingData
+func readRankingData(conf *config.Config) (*RankingData, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
@@ -85,7 +172,7 @@ func ReadRankingData(conf *config.Config) (*RankingData, error) {
 	collection := db.Collection(conf.MongoCollectionName)
 	cursor, err := collection.Find(ctx, bson.M{})
 	if err != nil {
-		fmt.Println("Warning: No ranking data found, creating new collection")
+		slog.Warn("no ranking data found, creating new collection", "error", err)
 		// return an empty ranking data
 		return &rankingData, nil
 	}
@@ -97,14 +184,30 @@ func ReadRankingData(conf *config.Config) (*RankingData, error) {
 		if err != nil {
 			return nil, err
 		}
+		channelRankingData.assignChallengeIDs()
+		channelRankingData.migrateTimeout()
+		channelRankingData.migrateOpenAdmin()
 		rankingData.Channels = append(rankingData.Channels, &channelRankingData)
 	}
 
+	rankingData.AuditLog, err = readAuditLog(ctx, db.Collection(rankingData.auditCollectionName()))
+	if err != nil {
+		return nil, err
+	}
+
 	return &rankingData, nil
 }
 
 // function that writes a RankingData struct to a mongodb
 func (rankingData *RankingData) Write() error {
+	start := time.Now()
+	err := rankingData.write()
+	metrics.ObserveStorage("write", start, err)
+	return err
+}
+
+// private function that writes the ranking data, see Write
+func (rankingData *RankingData) write() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -114,20 +217,35 @@ func (rankingData *RankingData) Write() error {
 	}
 	defer client.Disconnect(ctx)
 
-	collection := client.Database(rankingData.conf.MongoDBName).Collection(rankingData.conf.MongoCollectionName)
-	// delete all documents in the collection, ignore errors for empty results
-	_ = collection.Drop(ctx)
+	db := client.Database(rankingData.conf.MongoDBName)
+	if err := rankingData.writeAuditLog(ctx, db.Collection(rankingData.auditCollectionName())); err != nil {
+		return err
+	}
+
+	collection := db.Collection(rankingData.conf.MongoCollectionName)
+
+	// hold the locks for the whole write so concurrent writes (from Discord and
+	// the web server) can't interleave and readers don't see partial writes
+	rankingData.mutex.Lock()
+	defer rankingData.mutex.Unlock()
 
-	// insert each channel into the collection
+	// replace each channel's document, inserting it if it is new
+	channelIDs := make([]string, 0, len(rankingData.Channels))
 	for i := range rankingData.Channels {
-		channel := &rankingData.Channels[i]
-		_, err := collection.InsertOne(ctx, channel)
+		channel := rankingData.Channels[i]
+		channel.mutex.Lock()
+		_, err := collection.ReplaceOne(ctx, bson.M{"channel_id": channel.ChannelID}, channel,
+			options.Replace().SetUpsert(true))
+		channel.mutex.Unlock()
 		if err != nil {
 			return err
 		}
+		channelIDs = append(channelIDs, channel.ChannelID)
 	}
 
-	return nil
+	// delete the documents of removed channels
+	_, err = collection.DeleteMany(ctx, bson.M{"channel_id": bson.M{"$nin": channelIDs}})
+	return err
 }
 
 //
@@ -158,24 +276,334 @@ func (channel *ChannelRankingData) findPlayer(playerID string) (*Player, error)
 	return nil, errors.New("player not found")
 }
 
-// function that finds a challenge in a RankingData channel struct
-func (channel *ChannelRankingData) findChallenge(playerID string) (*Challenge, error) {
+// function that returns the next unique challenge ID for a channel
+func (channel *ChannelRankingData) nextChallengeID() string {
+	channel.LastChallengeID++
+	return strconv.Itoa(channel.LastChallengeID)
+}
+
+// function that fixes timeouts stored as a bare number of days, new channels
+// used to get a timeout of 7 (nanoseconds) instead of 7 days
+func (channel *ChannelRankingData) migrateTimeout() {
+	if channel.ChallengeTimeoutDays > 0 && channel.ChallengeTimeoutDays < time.Hour {
+		channel.ChallengeTimeoutDays = channel.ChallengeTimeoutDays * 24 * time.Hour
+	}
+}
+
+// function that keeps channels saved before open_admin existed working, an
+// empty admin list used to mean everyone is an admin. It runs once per channel
+// so open_admin can be turned off afterwards.
+func (channel *ChannelRankingData) migrateOpenAdmin() {
+	if channel.AdminsMigrated {
+		return
+	}
+	if len(channel.Admins) == 0 && !channel.OpenAdmin {
+		slog.Warn("channel has no admins, turning on open_admin so everyone stays an admin", "channel", channel.ChannelID)
+		channel.OpenAdmin = true
+	}
+	channel.AdminsMigrated = true
+}
+
+// function that gives an ID to any challenge or result stored before IDs existed
+func (channel *ChannelRankingData) assignChallengeIDs() {
+	for i := range channel.ResultHistory {
+		if channel.ResultHistory[i].ChallengeID == "" {
+			channel.ResultHistory[i].ChallengeID = channel.nextChallengeID()
+		}
+	}
+	for i := range channel.ActiveChallenges {
+		if channel.ActiveChallenges[i].ChallengeID == "" {
+			channel.ActiveChallenges[i].ChallengeID = channel.nextChallengeID()
+		}
+	}
+}
+
+// function that finds a challenge by its ID
+func (channel *ChannelRankingData) findChallengeByID(challengeID string) (*Challenge, error) {
 	for i := range channel.ActiveChallenges {
 		challenge := &channel.ActiveChallenges[i]
-		if challenge.ChallengerID == playerID || challenge.DefenderID == playerID {
+		if challenge.ChallengeID == challengeID {
 			return challenge, nil
 		}
 	}
 	return nil, errors.New("challenge not found")
 }
 
-// function that determines if a player is available for a challenge
-func (channel *ChannelRankingData) isPlayerAvailable(playerID string) bool {
-	_, err := channel.findChallenge(playerID)
-	player, _ := channel.findPlayer(playerID)
+// function that finds all challenges a player is in
+func (channel *ChannelRankingData) findChallenges(playerID string) []*Challenge {
+	challenges := []*Challenge{}
+	for i := range channel.ActiveChallenges {
+		challenge := &channel.ActiveChallenges[i]
+		if challenge.ChallengerID == playerID || challenge.DefenderID == playerID {
+			challenges = append(challenges, challenge)
+		}
+	}
+	return challenges
+}
+
+// function that finds the challenge a player means. The challenge ID may be
+// left empty when the player is only in one challenge.
+func (channel *ChannelRankingData) findPlayerChallenge(playerID string, challengeID string) (*Challenge, error) {
+	if challengeID != "" {
+		challenge, err := channel.findChallengeByID(challengeID)
+		if err != nil {
+			return nil, err
+		}
+		if challenge.ChallengerID != playerID && challenge.DefenderID != playerID {
+			return nil, errors.New("reporter is not in the challenge")
+		}
+		return challenge, nil
+	}
+
+	challenges := channel.findChallenges(playerID)
+	switch len(challenges) {
+	case 0:
+		return nil, errors.New("challenge not found")
+	case 1:
+		return challenges[0], nil
+	default:
+		return nil, errors.New("player is in multiple challenges, please specify a challenge ID")
+	}
+}
+
+// function that finds a result in the history by its challenge ID
+func (channel *ChannelRankingData) findResultByID(challengeID string) (*ResultHistory, error) {
+	for i := range channel.ResultHistory {
+		result := &channel.ResultHistory[i]
+		if result.ChallengeID == challengeID {
+			return result, nil
+		}
+	}
+	return nil, errors.New("match not found")
+}
+
+// function that returns a Discord formatted name for a player, even if they
+// have since left the ladder
+func (channel *ChannelRankingData) playerName(playerID string) string {
+	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return fmt.Sprintf("<@%s>", playerID)
+	}
+	return fmt.Sprintf("%s/<@%s>", player.GameName, player.PlayerID)
+}
+
+// function that removes a challenge by its ID
+func (channel *ChannelRankingData) removeChallenge(challengeID string) {
+	for i := range channel.ActiveChallenges {
+		if channel.ActiveChallenges[i].ChallengeID == challengeID {
+			channel.ActiveChallenges = append(channel.ActiveChallenges[:i], channel.ActiveChallenges[i+1:]...)
+			return
+		}
+	}
+}
+
+// function that returns the concurrent challenge limits for a channel.
+// With no limits configured, a player may only be in one challenge at a time.
+func (channel *ChannelRankingData) challengeLimits() (maxOutgoing int, maxIncoming int, exclusive bool) {
+	maxOutgoing = channel.MaxOutgoing
+	maxIncoming = channel.MaxIncoming
+	exclusive = maxOutgoing == 0 && maxIncoming == 0
+	if maxOutgoing == 0 {
+		maxOutgoing = 1
+	}
+	if maxIncoming == 0 {
+		maxIncoming = 1
+	}
+	return maxOutgoing, maxIncoming, exclusive
+}
+
+// function that determines if two players are available for a challenge
+func (channel *ChannelRankingData) checkAvailability(challenger *Player, defender *Player) error {
+	if challenger.Status != "active" {
+		return errors.New("challenger is not active")
+	}
+	if defender.Status != "active" {
+		return errors.New("defender is not active")
+	}
+
+	maxOutgoing, maxIncoming, exclusive := channel.challengeLimits()
+	outgoing := 0
+	incoming := 0
+	for i := range channel.ActiveChallenges {
+		challenge := &channel.ActiveChallenges[i]
+		if (challenge.ChallengerID == challenger.PlayerID && challenge.DefenderID == defender.PlayerID) ||
+			(challenge.ChallengerID == defender.PlayerID && challenge.DefenderID == challenger.PlayerID) {
+			return errors.New("players are already in a challenge with each other")
+		}
+		if exclusive {
+			if challenge.ChallengerID == challenger.PlayerID || challenge.DefenderID == challenger.PlayerID {
+				return errors.New("challenger is already in a challenge")
+			}
+			if challenge.ChallengerID == defender.PlayerID || challenge.DefenderID == defender.PlayerID {
+				return errors.New("defender is already in a challenge")
+			}
+		}
+		if challenge.ChallengerID == challenger.PlayerID {
+			outgoing++
+		}
+		if challenge.DefenderID == defender.PlayerID {
+			incoming++
+		}
+	}
+
+	if outgoing >= maxOutgoing {
+		return fmt.Errorf("challenger already has the maximum of %d outgoing challenges", maxOutgoing)
+	}
+	if incoming >= maxIncoming {
+		return fmt.Errorf("defender already has the maximum of %d incoming challenges", maxIncoming)
+	}
+	return nil
+}
+
+// function that returns the reach limits for a channel, falling back to the
+// defaults of the challenge mode for any limit that isn't explicitly set
+func (channel *ChannelRankingData) reachLimits() (maxPositions int, maxTiers int, err error) {
+	maxPositions = channel.MaxPositionsUp
+	maxTiers = channel.MaxTiersUp
+
+	switch channel.ChallengeMode {
+	// in linear/ladder mode, the challenger can only challenge the next person up
+	case "linear", "ladder":
+		if maxPositions == 0 {
+			maxPositions = 1
+		}
+	// in pyramid mode, the challenger can only challenge someone in the same tier or the tier above
+	case "pyramid":
+		if maxTiers == 0 {
+			maxTiers = 1
+		}
+	case "open":
+		// in open mode, only the explicit limits apply
+	default:
+		return 0, 0, errors.New("invalid challenge mode")
+	}
+	return maxPositions, maxTiers, nil
+}
+
+// function that returns the position of a player used for reach checks.
+// When inactive players may be skipped, they are not counted.
+func (channel *ChannelRankingData) reachPosition(player *Player) int {
+	if !channel.SkipInactive {
+		return player.Position
+	}
+	pos := 0
+	for i := range channel.RankedPlayers {
+		other := &channel.RankedPlayers[i]
+		if other.Position <= player.Position && (other.Status == "active" || other.PlayerID == player.PlayerID) {
+			pos++
+		}
+	}
+	return pos
+}
+
+// function that returns the number of players counted for reach checks
+func (channel *ChannelRankingData) reachPlayerCount() int {
+	if !channel.SkipInactive {
+		return len(channel.RankedPlayers)
+	}
+	count := 0
+	for i := range channel.RankedPlayers {
+		if channel.RankedPlayers[i].Status == "active" {
+			count++
+		}
+	}
+	return count
+}
+
+// function that determines if the defender is within reach of the challenger
+func (channel *ChannelRankingData) checkReach(challenger *Player, defender *Player) error {
+	maxPositions, maxTiers, err := channel.reachLimits()
+	if err != nil {
+		return err
+	}
+
+	challengerPos := channel.reachPosition(challenger)
+	defenderPos := channel.reachPosition(defender)
+
+	// unless allowed, the defender must be a higher rank
+	distance := challengerPos - defenderPos
+	direction := "up"
+	if distance < 0 {
+		if !channel.AllowDownward {
+			return errors.New("defender is a lower rank")
+		}
+		distance = -distance
+		direction = "down"
+	}
+
+	if maxPositions == 1 && distance > 1 {
+		return fmt.Errorf("challenger may only challenge the next person %s", direction)
+	} else if maxPositions > 0 && distance > maxPositions {
+		return fmt.Errorf("challenger may only challenge up to %d positions away", maxPositions)
+	}
+
+	if maxTiers > 0 {
+		tierDistance := tierFromPos(challengerPos) - tierFromPos(defenderPos)
+		if tierDistance < 0 {
+			tierDistance = -tierDistance
+		}
+		if maxTiers == 1 && tierDistance > 1 {
+			return errors.New("challenger must be within one tier of defender")
+		} else if tierDistance > maxTiers {
+			return fmt.Errorf("challenger must be within %d tiers of defender", maxTiers)
+		}
+	}
+
+	if channel.MaxPercentUp > 0 {
+		// round up so small ladders always allow at least one position
+		maxDistance := (channel.reachPlayerCount()*channel.MaxPercentUp + 99) / 100
+		if distance > maxDistance {
+			return fmt.Errorf("challenger may only challenge up to %d%% of the ladder (%d positions) away",
+				channel.MaxPercentUp, maxDistance)
+		}
+	}
+
+	return nil
+}
+
+// function that checks the rematch cooldown and defense immunity windows
+func (channel *ChannelRankingData) checkCooldowns(challenger *Player, defender *Player, now time.Time) error {
+	window := channel.RematchCooldown
+	if channel.DefenseImmunity > window {
+		window = channel.DefenseImmunity
+	}
+	if window <= 0 {
+		return nil
+	}
 
-	// return true if the player is not in a challenge and is active
-	return err != nil && player.Status == "active"
+	// walk the history backwards, stopping once results are older than either window
+	for i := len(channel.ResultHistory) - 1; i >= 0; i-- {
+		result := &channel.ResultHistory[i]
+		if now.Sub(result.ResolveDate) > window {
+			break
+		}
+
+		// a challenger that lost to this defender must wait before a rematch
+		if channel.RematchCooldown > 0 && result.Result == "won" &&
+			result.ChallengerID == challenger.PlayerID && result.DefenderID == defender.PlayerID {
+			eligible := result.ResolveDate.Add(channel.RematchCooldown)
+			if now.Before(eligible) {
+				return fmt.Errorf("%s may not rechallenge %s until %s",
+					challenger.GameName, defender.GameName, discordTime(eligible))
+			}
+		}
+
+		// a defender that just played a defense is protected for a while
+		if channel.DefenseImmunity > 0 && result.DefenderID == defender.PlayerID &&
+			(result.Result == "won" || result.Result == "lost") {
+			eligible := result.ResolveDate.Add(channel.DefenseImmunity)
+			if now.Before(eligible) {
+				return fmt.Errorf("%s recently defended and may not be challenged until %s",
+					defender.GameName, discordTime(eligible))
+			}
+		}
+	}
+	return nil
+}
+
+// function that formats a time as a Discord timestamp
+func discordTime(t time.Time) string {
+	return fmt.Sprintf("<t:%d:f> (<t:%d:R>)", t.Unix(), t.Unix())
 }
 
 // function that returns the tier of a position
@@ -207,16 +635,126 @@ func (a byPosition) Len() int           { return len(a) }
 func (a byPosition) Less(i, j int) bool { return a[i].Position < a[j].Position }
 func (a byPosition) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
-// private function that sorts the players by position and fixes any gaps
-func (channel *ChannelRankingData) fixPositions() {
+// function that returns the current position of every player, used to detect
+// changes made before calling fixPositions
+func (channel *ChannelRankingData) positionSnapshot() map[string]int {
+	positions := make(map[string]int, len(channel.RankedPlayers))
+	for i := range channel.RankedPlayers {
+		positions[channel.RankedPlayers[i].PlayerID] = channel.RankedPlayers[i].Position
+	}
+	return positions
+}
+
+// private function that sorts the players by position and fixes any gaps,
+// then records any positions that changed since the snapshot was taken
+func (channel *ChannelRankingData) fixPositions(before map[string]int, reason string, challengeID string) {
 
 	// sort the players by position
 	sort.Sort(byPosition(channel.RankedPlayers))
 
-	// remove any gaps in the positions
+	// remove any gaps in the positions and track each player's best position
+	for i := range channel.RankedPlayers {
+		player := &channel.RankedPlayers[i]
+		player.Position = i + 1
+		if player.BestPosition == 0 || player.Position < player.BestPosition {
+			player.BestPosition = player.Position
+		}
+	}
+
+	// record players that moved or joined, then players that left
+	now := time.Now()
+	changes := []PositionChange{}
 	for i := range channel.RankedPlayers {
-		channel.RankedPlayers[i].Position = i + 1
+		player := &channel.RankedPlayers[i]
+		if before[player.PlayerID] != player.Position {
+			changes = append(changes, PositionChange{
+				PlayerID:    player.PlayerID,
+				OldPosition: before[player.PlayerID],
+				NewPosition: player.Position,
+				Date:        now,
+				Reason:      reason,
+				ChallengeID: challengeID,
+			})
+		}
+	}
+	for playerID, position := range before {
+		if _, err := channel.findPlayer(playerID); err != nil {
+			changes = append(changes, PositionChange{
+				PlayerID:    playerID,
+				OldPosition: position,
+				Date:        now,
+				Reason:      reason,
+				ChallengeID: challengeID,
+			})
+		}
+	}
+	channel.PositionHistory = append(channel.PositionHistory, changes...)
+	for _, change := range changes {
+		channel.addEvent(Event{
+			Type:        EventPositionChanged,
+			Date:        change.Date,
+			PlayerID:    change.PlayerID,
+			ChallengeID: change.ChallengeID,
+			OldPosition: change.OldPosition,
+			NewPosition: change.NewPosition,
+		})
+	}
+}
+
+// function that computes a player's stats from the result history
+func (channel *ChannelRankingData) playerStats(playerID string) (PlayerStats, error) {
+	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+
+	stats := PlayerStats{
+		Player:       *player,
+		Tier:         tierFromPos(player.Position),
+		BestPosition: player.BestPosition,
+	}
+	if stats.BestPosition == 0 || player.Position < stats.BestPosition {
+		stats.BestPosition = player.Position
+	}
+
+	for i := range channel.ResultHistory {
+		result := &channel.ResultHistory[i]
+		var won bool
+		if result.ChallengerID == playerID {
+			won = result.ChallengerWon()
+			if won {
+				stats.ChallengerWins++
+			} else {
+				stats.ChallengerLosses++
+			}
+		} else if result.DefenderID == playerID {
+			won = !result.ChallengerWon()
+			if won {
+				stats.DefenderWins++
+			} else if result.Result == "lost" {
+				stats.DefenderLosses++
+			} else {
+				stats.DefenderForfeits++
+			}
+		} else {
+			continue
+		}
+
+		// extend the streak or start a new one
+		if won && stats.Streak >= 0 {
+			stats.Streak++
+		} else if won {
+			stats.Streak = 1
+		} else if stats.Streak <= 0 {
+			stats.Streak--
+		} else {
+			stats.Streak = -1
+		}
+		if result.ResolveDate.After(stats.LastMatch) {
+			stats.LastMatch = result.ResolveDate
+		}
 	}
+	return stats, nil
 }
 
 //
@@ -228,36 +766,290 @@ func (channel *ChannelRankingData) FindPlayer(playerID string) (Player, error) {
 	defer channel.mutex.Unlock()
 
 	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return Player{}, err
+	}
 
 	// return a copy of the player struct
-	return *player, err
+	return *player, nil
+}
+
+// function that returns a player's stats
+func (channel *ChannelRankingData) PlayerStats(playerID string) (PlayerStats, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.playerStats(playerID)
+}
+
+// function that returns a Discord formatted string of a player's profile
+func (channel *ChannelRankingData) PrintProfile(playerID string) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	stats, err := channel.playerStats(playerID)
+	if err != nil {
+		return "", err
+	}
+
+	var response string
+	response += fmt.Sprintf("Profile for %s/<@%s>:\n", stats.Player.GameName, stats.Player.PlayerID)
+	response += fmt.Sprintf("  position: %d (tier %d)\n", stats.Player.Position, stats.Tier)
+	response += fmt.Sprintf("  best position: %d\n", stats.BestPosition)
+	response += fmt.Sprintf("  status: %s\n", stats.Player.Status)
+	response += fmt.Sprintf("  as challenger: %d wins, %d losses\n",
+		stats.ChallengerWins, stats.ChallengerLosses)
+	response += fmt.Sprintf("  as defender: %d wins, %d losses, %d forfeits\n",
+		stats.DefenderWins, stats.DefenderLosses, stats.DefenderForfeits)
+	if stats.Streak > 0 {
+		response += fmt.Sprintf("  streak: %d wins\n", stats.Streak)
+	} else if stats.Streak < 0 {
+		response += fmt.Sprintf("  streak: %d losses\n", -stats.Streak)
+	}
+	if stats.LastMatch.IsZero() {
+		response += "  last match: never\n"
+	} else {
+		response += fmt.Sprintf("  last match: %s\n", discordTime(stats.LastMatch))
+	}
+	if stats.Player.Notes != "" {
+		response += fmt.Sprintf("  notes: %s\n", stats.Player.Notes)
+	}
+	return response, nil
+}
+
+// function that checks a game mode is valid
+func CheckGameMode(gameMode string) error {
+	if gameMode != "ladder" && gameMode != "pyramid" && gameMode != "linear" && gameMode != "open" {
+		return errors.New("invalid game mode")
+	}
+	return nil
 }
 
 // function that sets the game mode for a channel
 func (channel *ChannelRankingData) SetGameMode(gameMode string) error {
 
 	//verify the game mode is valid
-	if gameMode != "ladder" && gameMode != "pyramid" && gameMode != "linear" && gameMode != "open" {
-		return errors.New("invalid game mode")
+	if err := CheckGameMode(gameMode); err != nil {
+		return err
 	}
 
 	channel.mutex.Lock()
 	defer channel.mutex.Unlock()
 
-	channel.ChallengeMode = gameMode
+	channel.ChallengeMode = gameMode
+	return nil
+}
+
+// function that checks a match timeout in days is valid
+func CheckTimeout(timeoutDays int) error {
+	if timeoutDays < 1 || timeoutDays > 30 {
+		return errors.New("timeout days must be between 1 and 30")
+	}
+	return nil
+}
+
+// function tht sets the timeout of matches for a channel
+func (channel *ChannelRankingData) SetTimeout(timeoutDays int) error {
+	if err := CheckTimeout(timeoutDays); err != nil {
+		return err
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.ChallengeTimeoutDays = time.Duration(timeoutDays) * 24 * time.Hour
+	return nil
+}
+
+// function that returns the timeout of matches for a channel in days
+func (channel *ChannelRankingData) TimeoutDays() int {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return int(channel.ChallengeTimeoutDays / (24 * time.Hour))
+}
+
+// function that sets how long a challenger must wait to rechallenge a defender they lost to
+func (channel *ChannelRankingData) SetRematchCooldown(hours int) error {
+	if hours < 0 || hours > 720 {
+		return errors.New("rematch cooldown hours must be between 0 and 720")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.RematchCooldown = time.Duration(hours) * time.Hour
+	return nil
+}
+
+// function that sets how long a defender is protected after a defense
+func (channel *ChannelRankingData) SetDefenseImmunity(hours int) error {
+	if hours < 0 || hours > 720 {
+		return errors.New("defense immunity hours must be between 0 and 720")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.DefenseImmunity = time.Duration(hours) * time.Hour
+	return nil
+}
+
+// function that sets the maximum number of positions a challenger may reach (0 for the mode default)
+func (channel *ChannelRankingData) SetMaxPositionsUp(positions int) error {
+	if positions < 0 {
+		return errors.New("max positions up must not be negative")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.MaxPositionsUp = positions
+	return nil
+}
+
+// function that sets the maximum number of tiers a challenger may reach (0 for the mode default)
+func (channel *ChannelRankingData) SetMaxTiersUp(tiers int) error {
+	if tiers < 0 {
+		return errors.New("max tiers up must not be negative")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.MaxTiersUp = tiers
+	return nil
+}
+
+// function that sets the maximum percentage of the ladder a challenger may reach (0 to disable)
+func (channel *ChannelRankingData) SetMaxPercentUp(percent int) error {
+	if percent < 0 || percent > 100 {
+		return errors.New("max percent up must be between 0 and 100")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.MaxPercentUp = percent
+	return nil
+}
+
+// function that sets whether inactive players are skipped when counting reach
+func (channel *ChannelRankingData) SetSkipInactive(skip bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.SkipInactive = skip
+}
+
+// function that sets whether players may challenge players below them
+func (channel *ChannelRankingData) SetAllowDownward(allow bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.AllowDownward = allow
+}
+
+// function that sets the maximum outgoing and incoming challenges per player.
+// Setting both to 0 restores the default of one challenge at a time.
+func (channel *ChannelRankingData) SetMaxOutgoing(max int) error {
+	if max < 0 || max > 25 {
+		return errors.New("max outgoing challenges must be between 0 and 25")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.MaxOutgoing = max
+	return nil
+}
+
+// function that sets the maximum incoming challenges per player
+func (channel *ChannelRankingData) SetMaxIncoming(max int) error {
+	if max < 0 || max > 25 {
+		return errors.New("max incoming challenges must be between 0 and 25")
+	}
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.MaxIncoming = max
 	return nil
 }
 
-// function tht sets the timeout of matches for a channel
-func (channel *ChannelRankingData) SetTimeout(timeoutDays int) error {
-	if timeoutDays < 1 || timeoutDays > 30 {
-		return errors.New("timeout days must be between 1 and 30")
+// function that returns a Discord formatted description of the challenge rules
+func (channel *ChannelRankingData) PrintChallengeRules() (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.printChallengeRules()
+}
+
+// private function that describes the challenge rules, the channel must be locked
+func (channel *ChannelRankingData) printChallengeRules() (string, error) {
+	maxPositions, maxTiers, err := channel.reachLimits()
+	if err != nil {
+		return "", err
+	}
+
+	response := fmt.Sprintf("Challenge rules (mode: %s):\n", channel.ChallengeMode)
+	if channel.AllowDownward {
+		response += "  - players may challenge up or down the ladder, challenges down never change positions\n"
+	} else {
+		response += "  - players may only challenge up the ladder\n"
+	}
+	if maxPositions > 0 {
+		response += fmt.Sprintf("  - at most %d positions away\n", maxPositions)
 	}
+	if maxTiers > 0 {
+		response += fmt.Sprintf("  - at most %d tiers away\n", maxTiers)
+	}
+	if channel.MaxPercentUp > 0 {
+		response += fmt.Sprintf("  - at most %d%% of the ladder away\n", channel.MaxPercentUp)
+	}
+	if maxPositions == 0 && maxTiers == 0 && channel.MaxPercentUp == 0 {
+		response += "  - any distance\n"
+	}
+	if channel.SkipInactive {
+		response += "  - inactive players are skipped when counting\n"
+	}
+	maxOutgoing, maxIncoming, exclusive := channel.challengeLimits()
+	if exclusive {
+		response += "  - players may be in one challenge at a time\n"
+	} else {
+		response += fmt.Sprintf("  - players may have up to %d outgoing and %d incoming challenges\n",
+			maxOutgoing, maxIncoming)
+	}
+	if channel.RematchCooldown > 0 {
+		response += fmt.Sprintf("  - %d hours before a rematch against a defender you lost to\n",
+			int(channel.RematchCooldown.Hours()))
+	}
+	if channel.DefenseImmunity > 0 {
+		response += fmt.Sprintf("  - defenders are protected for %d hours after a defense\n",
+			int(channel.DefenseImmunity.Hours()))
+	}
+	return response, nil
+}
+
+// function that returns a Discord formatted list of the system settings and challenge rules
+func (channel *ChannelRankingData) PrintSettings() (string, error) {
 	channel.mutex.Lock()
 	defer channel.mutex.Unlock()
 
-	channel.ChallengeTimeoutDays = time.Duration(timeoutDays) * 24 * time.Hour
-	return nil
+	var response string
+	response += "Game settings:\n"
+	response += fmt.Sprintf("  gamemode: %s\n", channel.ChallengeMode)
+	response += fmt.Sprintf("  timeout: %d (days)\n", int(channel.ChallengeTimeoutDays/(24*time.Hour)))
+	response += fmt.Sprintf("  rematch cooldown: %d (hours)\n", int(channel.RematchCooldown.Hours()))
+	response += fmt.Sprintf("  defense immunity: %d (hours)\n", int(channel.DefenseImmunity.Hours()))
+	response += "  admins: "
+	for _, admin := range channel.Admins {
+		response += fmt.Sprintf("<@%s> ", admin)
+	}
+	response += "\n"
+	response += fmt.Sprintf("  admin roles: %s\n", RoleMentions(channel.AdminRoles))
+	response += fmt.Sprintf("  moderator roles: %s\n", RoleMentions(channel.ModeratorRoles))
+	response += fmt.Sprintf("  open admin: %t\n", channel.OpenAdmin)
+	response += fmt.Sprintf("  scheduled events: %t\n", channel.ScheduledEvents)
+	response += fmt.Sprintf("  leaderboard: %t\n", channel.Leaderboard)
+	response += fmt.Sprintf("  notes: %s\n", channel.Notes)
+	rules, err := channel.printChallengeRules()
+	if err != nil {
+		return "", err
+	}
+	return response + rules, nil
 }
 
 // function that adds an admin to a channel
@@ -307,6 +1099,30 @@ func (channel *ChannelRankingData) SetNotes(notes string) error {
 	return nil
 }
 
+// function that sets whether the channel keeps a pinned leaderboard
+func (channel *ChannelRankingData) SetLeaderboard(enabled bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.Leaderboard = enabled
+}
+
+// function that returns whether the channel keeps a pinned leaderboard, and its message
+func (channel *ChannelRankingData) LeaderboardState() (bool, string) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.Leaderboard, channel.LeaderboardMessageID
+}
+
+// function that remembers the pinned leaderboard message, empty when there is none
+func (channel *ChannelRankingData) SetLeaderboardMessage(messageID string) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.LeaderboardMessageID = messageID
+}
+
 // function that prints a RankingData struct
 func (channel *ChannelRankingData) PrintRaw() (string, error) {
 	//lock the mutex
@@ -325,6 +1141,12 @@ func (channel *ChannelRankingData) PrintRankings() (string, error) {
 	// lock the mutex
 	channel.mutex.Lock()
 	defer channel.mutex.Unlock()
+
+	return channel.printRankings()
+}
+
+// private function that builds the standings, the channel must be locked
+func (channel *ChannelRankingData) printRankings() (string, error) {
 	tier := 0
 	tierdiv := 1
 	var response string
@@ -349,56 +1171,32 @@ func (channel *ChannelRankingData) PrintRankings() (string, error) {
 			tierdiv += tier
 		}
 
-		chal, err := channel.findChallenge(player.PlayerID)
-		if err != nil {
-			// player is not in a challenge
-			response += fmt.Sprintf("%d. %s/<@%s>\n", pos, player.GameName, player.PlayerID)
-		} else {
+		response += fmt.Sprintf("%d. %s/<@%s>", pos, player.GameName, player.PlayerID)
+
+		// note any challenges the player is in
+		for _, chal := range channel.findChallenges(player.PlayerID) {
 			if chal.ChallengerID == player.PlayerID {
 				// player is the challenger
 				defender, err := channel.findPlayer(chal.DefenderID)
 				if err != nil {
 					return "", errors.New("defender not found")
 				}
-				response += fmt.Sprintf("%d. %s/<@%s> (challenging %s/<@%s>)\n", pos,
-					player.GameName, player.PlayerID,
-					defender.GameName, chal.DefenderID)
+				response += fmt.Sprintf(" (challenging %s/<@%s>)", defender.GameName, chal.DefenderID)
 			} else {
 				// player is the defender
 				challenger, err := channel.findPlayer(chal.ChallengerID)
 				if err != nil {
 					return "", errors.New("challenger not found")
 				}
-				response += fmt.Sprintf("%d. %s/<@%s> (challenged by %s/<@%s>)\n", pos,
-					player.GameName, player.PlayerID,
-					challenger.GameName, chal.ChallengerID)
+				response += fmt.Sprintf(" (challenged by %s/<@%s>)", challenger.GameName, chal.ChallengerID)
 			}
 		}
+		response += "\n"
 	}
 
 	return response, nil
 }
 
-// function that verifies if a player is an admin
-func (channel *ChannelRankingData) IsAdmin(playerID string) bool {
-	//lock the mutex
-	channel.mutex.Lock()
-	defer channel.mutex.Unlock()
-
-	// if the admin list is empty, then everyone is an admin
-	if len(channel.Admins) == 0 {
-		return true
-	}
-
-	// walk through the admins and see if the player is in the list
-	for _, admin := range channel.Admins {
-		if admin == playerID {
-			return true
-		}
-	}
-	return false
-}
-
 // function that returns a Discord formatted string of the active challenges
 func (channel *ChannelRankingData) PrintChallenges() (string, error) {
 	//lock the mutex
@@ -420,9 +1218,17 @@ func (channel *ChannelRankingData) PrintChallenges() (string, error) {
 		if err != nil {
 			return "", errors.New("challenger not found")
 		}
-		response += fmt.Sprintf("%s/<@%s>(#%d) vs %s/<@%s>(#%d)\n",
+		response += fmt.Sprintf("[%s] %s/<@%s>(#%d) vs %s/<@%s>(#%d)",
+			challenge.ChallengeID,
 			challenger.GameName, challenger.PlayerID, challenger.Position,
 			defender.GameName, defender.PlayerID, defender.Position)
+		if !challenge.ScheduledTime.IsZero() {
+			response += fmt.Sprintf(" at %s", discordTime(challenge.ScheduledTime))
+		}
+		if challenge.ThreadID != "" {
+			response += fmt.Sprintf(" in <#%s>", challenge.ThreadID)
+		}
+		response += "\n"
 	}
 
 	return response, nil
@@ -447,7 +1253,8 @@ func (channel *ChannelRankingData) PrintHistory() (string, error) {
 			return "", errors.New("challenger not found")
 		}
 		// TODO add dates
-		response += fmt.Sprintf("%s/<@%s> vs %s/<@%s> (%s)\n",
+		response += fmt.Sprintf("[%s] %s/<@%s> vs %s/<@%s> (%s)\n",
+			result.ChallengeID,
 			challenger.GameName, challenger.PlayerID,
 			defender.GameName, defender.PlayerID,
 			result.Result)
@@ -471,11 +1278,13 @@ func (rankingData *RankingData) AddChannel(channelID string, adminID string) (st
 		&ChannelRankingData{
 			ChannelID:            channelID,
 			ChallengeMode:        "ladder",
-			ChallengeTimeoutDays: 7,
+			ChallengeTimeoutDays: 7 * 24 * time.Hour,
 			RankedPlayers:        []Player{},
 			ActiveChallenges:     []Challenge{},
 			ResultHistory:        []ResultHistory{},
+			PositionHistory:      []PositionChange{},
 			Admins:               []string{adminID},
+			AdminsMigrated:       true,
 		})
 	return "Let the games begin!", nil
 }
@@ -500,6 +1309,18 @@ func (rankingData *RankingData) RemoveChannel(channelID string) (string, error)
 	return "", errors.New("channel not found")
 }
 
+// function that returns the IDs of all registered channels
+func (rankingData *RankingData) ChannelIDs() []string {
+	rankingData.mutex.Lock()
+	defer rankingData.mutex.Unlock()
+
+	channelIDs := make([]string, 0, len(rankingData.Channels))
+	for _, channel := range rankingData.Channels {
+		channelIDs = append(channelIDs, channel.ChannelID)
+	}
+	return channelIDs
+}
+
 // function that finds a channel in a RankingData struct
 func (rankingData *RankingData) FindChannel(channelID string) (*ChannelRankingData, error) {
 	rankingData.mutex.Lock()
@@ -519,14 +1340,19 @@ func (channel *ChannelRankingData) AddPlayer(playerID string, gameName string) (
 	}
 
 	// add the player to the ranking data
+	before := channel.positionSnapshot()
 	channel.RankedPlayers = append(channel.RankedPlayers,
 		Player{
-			PlayerID: playerID,
-			Position: len(channel.RankedPlayers) + 1,
-			GameName: gameName,
-			Status:   "active",
-			Notes:    "",
+			PlayerID:     playerID,
+			Position:     len(channel.RankedPlayers) + 1,
+			BestPosition: len(channel.RankedPlayers) + 1,
+			GameName:     gameName,
+			Status:       "active",
+			Notes:        "",
 		})
+	channel.addEvent(Event{Type: EventPlayerRegistered, PlayerID: playerID})
+	channel.fixPositions(before, "registered", "")
+
 	return fmt.Sprintf("Added %s/<@%s> to position %d",
 		gameName,
 		playerID,
@@ -539,6 +1365,7 @@ func (channel *ChannelRankingData) RemovePlayer(playerID string) (string, error)
 	defer channel.mutex.Unlock()
 
 	// return an error if the player is not present
+	before := channel.positionSnapshot()
 	removedPos := 0
 	var gamename string
 	for i := range channel.RankedPlayers {
@@ -556,16 +1383,24 @@ func (channel *ChannelRankingData) RemovePlayer(playerID string) (string, error)
 	}
 
 	// decrement the position of all players below the removed player
-	channel.fixPositions()
-
-	// remove any active challenges that the player is in
-	for i := range channel.ActiveChallenges {
-		challenge := &channel.ActiveChallenges[i]
-		if challenge.ChallengerID == playerID || challenge.DefenderID == playerID {
-			channel.ActiveChallenges = append(channel.ActiveChallenges[:i], channel.ActiveChallenges[i+1:]...)
-			break
-		}
+	channel.fixPositions(before, "unregistered", "")
+
+	// remove any active challenges that the player is in, the events are made
+	// first since removing challenges moves the others around
+	for _, challenge := range channel.findChallenges(playerID) {
+		channel.addEvent(Event{
+			Type:         EventChallengeCanceled,
+			PlayerID:     challenge.ChallengerID,
+			OpponentID:   challenge.DefenderID,
+			ChallengeID:  challenge.ChallengeID,
+			ThreadID:     challenge.ThreadID,
+			GuildEventID: challenge.GuildEventID,
+		})
 	}
+	channel.ActiveChallenges = slices.DeleteFunc(channel.ActiveChallenges, func(challenge Challenge) bool {
+		return challenge.ChallengerID == playerID || challenge.DefenderID == playerID
+	})
+	channel.addEvent(Event{Type: EventPlayerUnregistered, PlayerID: playerID, OldPosition: removedPos})
 
 	return fmt.Sprintf("Removed %s/<@%s> from position %d",
 		gamename,
@@ -593,10 +1428,11 @@ func (channel *ChannelRankingData) MovePlayer(playerID string, newPosition int)
 	gamename := movingPlayer.GameName
 
 	// return error if the player is in a challenge
-	if _, err := channel.findChallenge(playerID); err == nil {
+	if len(channel.findChallenges(playerID)) > 0 {
 		return "", errors.New("player is in a challenge")
 	}
 
+	before := channel.positionSnapshot()
 	for i := range channel.RankedPlayers {
 		player := &channel.RankedPlayers[i]
 
@@ -610,7 +1446,7 @@ func (channel *ChannelRankingData) MovePlayer(playerID string, newPosition int)
 	movingPlayer.Position = newPosition
 
 	// clean up the positions
-	channel.fixPositions()
+	channel.fixPositions(before, "moved", "")
 
 	return fmt.Sprintf("Moved %s/<@%s> to position %d",
 		gamename,
@@ -633,49 +1469,39 @@ func (channel *ChannelRankingData) StartChallenge(challengerID string, defenderI
 		return "", errors.New("defender not found")
 	}
 
-	// if the challenger is not available, return an error
-	// TODO: it would be good to make the reasoning for the error more specific
-	if !channel.isPlayerAvailable(challengerID) {
-		return "", errors.New("challenger is not available")
-	}
-	if !channel.isPlayerAvailable(defenderID) {
-		return "", errors.New("defender is not available")
+	// if either player is not available, return an error
+	if err := channel.checkAvailability(challenger, defender); err != nil {
+		return "", err
 	}
 
-	// if the defender is a lower rank, it's invalid
-	if challenger.Position < defender.Position {
-		return "", errors.New("defender is a lower rank")
+	// make sure the defender is within reach of the challenger
+	if err := channel.checkReach(challenger, defender); err != nil {
+		return "", err
 	}
 
-	switch channel.ChallengeMode {
-	// in linear/ladder mode, the challenger can only challenge the next person up
-	case "linear", "ladder":
-		if challenger.Position-1 != defender.Position {
-			return "", errors.New("challenger may only challenge the next person up")
-		}
-	// in pyramid mode, the challenger can only challenge someone in the same tier or the tier below
-	case "pyramid":
-		// determine if the challenger is eligible to challenge defender
-		challengerTier := tierFromPos(challenger.Position)
-		defenderTier := tierFromPos(defender.Position)
-		if challengerTier-defenderTier > 1 {
-			return "", errors.New("challenger must be within one tier of defender")
-		}
-	case "open":
-		// in open mode, the challenger can challenge anyone
-	default:
-		return "", errors.New("invalid challenge mode")
+	// make sure neither the rematch cooldown nor defense immunity applies
+	if err := channel.checkCooldowns(challenger, defender, time.Now()); err != nil {
+		return "", err
 	}
 
 	// create the challenge
+	challengeID := channel.nextChallengeID()
 	channel.ActiveChallenges = append(channel.ActiveChallenges,
 		Challenge{
+			ChallengeID:       challengeID,
 			ChallengerID:      challengerID,
 			DefenderID:        defenderID,
 			ChallengeDate:     time.Now(),
 			ChallengeDeadline: time.Now().Add(channel.ChallengeTimeoutDays),
 		})
-	response := fmt.Sprintf("Challenge started: %s/<@%s> vs %s/<@%s>",
+	channel.addEvent(Event{
+		Type:        EventChallengeStarted,
+		PlayerID:    challengerID,
+		OpponentID:  defenderID,
+		ChallengeID: challengeID,
+	})
+	response := fmt.Sprintf("Challenge [%s] started: %s/<@%s> vs %s/<@%s>",
+		challengeID,
 		challenger.GameName, challenger.PlayerID,
 		defender.GameName, defender.PlayerID)
 	return response, nil
@@ -683,17 +1509,316 @@ func (channel *ChannelRankingData) StartChallenge(challengerID string, defenderI
 
 // TODO, have the cancel challenge function omit the challenge from the history
 
-// function that resolves a challenge
-func (channel *ChannelRankingData) ResolveChallenge(reporterID string, action string) (string, error) {
+// function that returns a Discord formatted string of the record between two players
+func (channel *ChannelRankingData) PrintHeadToHead(playerA string, playerB string, limit int) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if playerA == playerB {
+		return "", errors.New("please pick two different players")
+	}
+
+	// collect the meetings between the two players
+	winsA := 0
+	winsB := 0
+	meetings := []*ResultHistory{}
+	for i := range channel.ResultHistory {
+		result := &channel.ResultHistory[i]
+		if !(result.ChallengerID == playerA && result.DefenderID == playerB) &&
+			!(result.ChallengerID == playerB && result.DefenderID == playerA) {
+			continue
+		}
+		if (result.ChallengerID == playerA) == result.ChallengerWon() {
+			winsA++
+		} else {
+			winsB++
+		}
+		meetings = append(meetings, result)
+	}
+
+	nameA := channel.playerName(playerA)
+	nameB := channel.playerName(playerB)
+	if len(meetings) == 0 {
+		return fmt.Sprintf("%s and %s have not played each other yet", nameA, nameB), nil
+	}
+
+	var response string
+	response += fmt.Sprintf("Head to head, %s vs %s:\n", nameA, nameB)
+	response += fmt.Sprintf("  %s: %d wins\n", nameA, winsA)
+	response += fmt.Sprintf("  %s: %d wins\n", nameB, winsB)
+
+	// show the most recent meetings first
+	if limit <= 0 || limit > len(meetings) {
+		limit = len(meetings)
+	}
+	response += fmt.Sprintf("Last %d meetings:\n", limit)
+	for i := len(meetings) - 1; i >= len(meetings)-limit; i-- {
+		result := meetings[i]
+		winner := result.DefenderID
+		if result.ChallengerWon() {
+			winner = result.ChallengerID
+		}
+		response += fmt.Sprintf("  [%s] %s: %s challenged %s, %s won",
+			result.ChallengeID, discordTime(result.ResolveDate),
+			channel.playerName(result.ChallengerID), channel.playerName(result.DefenderID),
+			channel.playerName(winner))
+		if result.Result == "forfeit" || result.Result == "timed out" {
+			response += fmt.Sprintf(" (%s)", result.Result)
+		}
+		if result.Score != "" {
+			response += fmt.Sprintf(" %s", result.Score)
+		}
+		if result.ChallengerWon() && result.ChallengerPosition > result.DefenderPosition && result.DefenderPosition > 0 {
+			response += fmt.Sprintf(", #%d -> #%d", result.ChallengerPosition, result.DefenderPosition)
+		}
+		response += "\n"
+	}
+	return response, nil
+}
+
+// function that returns the challenge mode of a channel
+func (channel *ChannelRankingData) Mode() string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.ChallengeMode
+}
+
+// function that returns a copy of the ranked players in position order
+func (channel *ChannelRankingData) Standings() []Player {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	players := make([]Player, len(channel.RankedPlayers))
+	copy(players, channel.RankedPlayers)
+	return players
+}
+
+// function that returns a copy of the active challenges
+func (channel *ChannelRankingData) Challenges() []Challenge {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenges := make([]Challenge, len(channel.ActiveChallenges))
+	copy(challenges, channel.ActiveChallenges)
+	return challenges
+}
+
+// function that returns a copy of an active challenge
+func (channel *ChannelRankingData) FindChallenge(challengeID string) (Challenge, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenge, err := channel.findChallengeByID(challengeID)
+	if err != nil {
+		return Challenge{}, err
+	}
+	return *challenge, nil
+}
+
+// function that remembers the private thread made for a challenge
+func (channel *ChannelRankingData) SetChallengeThread(challengeID string, threadID string) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenge, err := channel.findChallengeByID(challengeID)
+	if err != nil {
+		return err
+	}
+	challenge.ThreadID = threadID
+	return nil
+}
+
+// function that returns a copy of the most recent results, newest first.
+// A limit of 0 or less returns the whole history.
+func (channel *ChannelRankingData) History(limit int) []ResultHistory {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if limit <= 0 || limit > len(channel.ResultHistory) {
+		limit = len(channel.ResultHistory)
+	}
+	results := make([]ResultHistory, 0, limit)
+	for i := len(channel.ResultHistory) - 1; i >= len(channel.ResultHistory)-limit; i-- {
+		results = append(results, channel.ResultHistory[i])
+	}
+	return results
+}
+
+// function that returns a copy of a player's position changes, oldest first
+func (channel *ChannelRankingData) PositionTimeline(playerID string) []PositionChange {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	changes := []PositionChange{}
+	for _, change := range channel.PositionHistory {
+		if change.PlayerID == playerID {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// function that returns a copy of the players grouped into pyramid tiers
+func (channel *ChannelRankingData) PyramidTiers() [][]Player {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	tiers := [][]Player{}
+	for _, player := range channel.RankedPlayers {
+		tier := tierFromPos(player.Position)
+		for len(tiers) < tier {
+			size := maxPosInTier(len(tiers)+1) - maxPosInTier(len(tiers))
+			tiers = append(tiers, make([]Player, 0, size))
+		}
+		tiers[tier-1] = append(tiers[tier-1], player)
+	}
+	return tiers
+}
+
+// function that returns a Discord formatted string of a player's position changes
+func (channel *ChannelRankingData) PrintTimeline(playerID string, limit int) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	changes := []*PositionChange{}
+	for i := range channel.PositionHistory {
+		if channel.PositionHistory[i].PlayerID == playerID {
+			changes = append(changes, &channel.PositionHistory[i])
+		}
+	}
+
+	name := channel.playerName(playerID)
+	if len(changes) == 0 {
+		return fmt.Sprintf("No position changes recorded for %s", name), nil
+	}
+
+	// show the most recent changes, oldest first
+	if limit <= 0 || limit > len(changes) {
+		limit = len(changes)
+	}
+	changes = changes[len(changes)-limit:]
+
+	var response string
+	response += fmt.Sprintf("Position timeline for %s:\n", name)
+	for _, change := range changes {
+		response += fmt.Sprintf("  %s: ", discordTime(change.Date))
+		if change.OldPosition == 0 {
+			response += fmt.Sprintf("joined at #%d", change.NewPosition)
+		} else if change.NewPosition == 0 {
+			response += fmt.Sprintf("left from #%d", change.OldPosition)
+		} else {
+			response += fmt.Sprintf("#%d -> #%d", change.OldPosition, change.NewPosition)
+		}
+
+		switch change.Reason {
+		case "challenge":
+			response += fmt.Sprintf(" (match [%s])", change.ChallengeID)
+		case "moved":
+			response += " (moved by an admin)"
+		case "registered":
+			if change.OldPosition != 0 {
+				response += " (a player joined)"
+			}
+		case "unregistered":
+			if change.NewPosition != 0 {
+				response += " (a player left)"
+			}
+		}
+		response += "\n"
+	}
+	return response, nil
+}
+
+// function that returns a Discord formatted string with the details of a match
+func (channel *ChannelRankingData) PrintMatch(challengeID string) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	var response string
+	if challenge, err := channel.findChallengeByID(challengeID); err == nil {
+		response += fmt.Sprintf("Match [%s]:\n", challenge.ChallengeID)
+		response += fmt.Sprintf("  challenger: %s\n", channel.playerName(challenge.ChallengerID))
+		response += fmt.Sprintf("  defender: %s\n", channel.playerName(challenge.DefenderID))
+		response += fmt.Sprintf("  challenged: %s\n", discordTime(challenge.ChallengeDate))
+		response += fmt.Sprintf("  deadline: %s\n", discordTime(challenge.ChallengeDeadline))
+		response += "  status: active\n"
+		return response, nil
+	}
+
+	result, err := channel.findResultByID(challengeID)
+	if err != nil {
+		return "", err
+	}
+	response += fmt.Sprintf("Match [%s]:\n", result.ChallengeID)
+	response += fmt.Sprintf("  challenger: %s\n", channel.playerName(result.ChallengerID))
+	response += fmt.Sprintf("  defender: %s\n", channel.playerName(result.DefenderID))
+	if !result.ChallengeDate.IsZero() {
+		response += fmt.Sprintf("  challenged: %s\n", discordTime(result.ChallengeDate))
+	}
+	if !result.ChallengeDeadline.IsZero() {
+		response += fmt.Sprintf("  deadline: %s\n", discordTime(result.ChallengeDeadline))
+	}
+	response += fmt.Sprintf("  status: resolved (defender %s)\n", result.Result)
+	if !result.ResolveDate.IsZero() {
+		response += fmt.Sprintf("  resolved: %s\n", discordTime(result.ResolveDate))
+	}
+	if result.Score != "" {
+		response += fmt.Sprintf("  score: %s\n", result.Score)
+	}
+	if result.Admin {
+		response += "  reported by: an admin\n"
+	} else if result.ReporterID != "" {
+		response += fmt.Sprintf("  reported by: <@%s>\n", result.ReporterID)
+	}
+	return response, nil
+}
+
+// function that resolves a challenge. The challenge ID may be left empty
+// when the reporter is only in one challenge. The score is optional.
+func (channel *ChannelRankingData) ResolveChallenge(reporterID string, challengeID string, action string, score string) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.resolveChallenge(reporterID, "", challengeID, action, score)
+}
+
+// function that resolves a challenge on behalf of a player, used by admins.
+// The admin is recorded as the reporter.
+func (channel *ChannelRankingData) ResolveChallengeFor(adminID string, playerID string, challengeID string, action string, score string) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.resolveChallenge(playerID, adminID, challengeID, action, score)
+}
+
+// function that resolves a challenge by ID on behalf of the participants, used
+// by admins. Cancels are handled as the challenger's, everything else as the
+// defender's, and the admin is recorded as the reporter.
+func (channel *ChannelRankingData) ResolveChallengeByID(adminID string, challengeID string, action string, score string) (string, error) {
 	channel.mutex.Lock()
 	defer channel.mutex.Unlock()
 
+	challenge, err := channel.findChallengeByID(challengeID)
+	if err != nil {
+		return "", err
+	}
+	reporterID := challenge.DefenderID
+	if action == "cancel" {
+		reporterID = challenge.ChallengerID
+	}
+	return channel.resolveChallenge(reporterID, adminID, challengeID, action, score)
+}
+
+// private function that resolves a challenge, see ResolveChallenge. The admin
+// is set when an admin reports on behalf of the reporter.
+func (channel *ChannelRankingData) resolveChallenge(reporterID string, adminID string, challengeID string, action string, score string) (string, error) {
 	var result string
 
 	// find the challenge
-	challenge, err := channel.findChallenge(reporterID)
+	challenge, err := channel.findPlayerChallenge(reporterID, challengeID)
 	if err != nil {
-		return "", errors.New("challenge not found")
+		return "", err
 	}
 
 	// sanity check the action
@@ -717,19 +1842,6 @@ func (channel *ChannelRankingData) ResolveChallenge(reporterID string, action st
 		return "", errors.New("reporter is not in the challenge")
 	}
 
-	// add the result to the history only if not canceled
-	if action != "cancel" {
-
-		channel.ResultHistory = append(channel.ResultHistory,
-			ResultHistory{
-				ChallengerID:  challenge.ChallengerID,
-				DefenderID:    challenge.DefenderID,
-				Result:        action,
-				ChallengeDate: challenge.ChallengeDate,
-				ResolveDate:   time.Now(),
-			})
-	}
-
 	challenger, err := channel.findPlayer(challenge.ChallengerID)
 	if err != nil {
 		return "", errors.New("challenger not found")
@@ -739,13 +1851,50 @@ func (channel *ChannelRankingData) ResolveChallenge(reporterID string, action st
 		return "", errors.New("defender not found")
 	}
 
+	// an admin reporting for a player is recorded as the reporter
+	reportedBy := reporterID
+	if adminID != "" {
+		reportedBy = adminID
+	}
+
+	// add the result to the history only if not canceled
+	if action != "cancel" {
+
+		channel.ResultHistory = append(channel.ResultHistory,
+			ResultHistory{
+				ChallengeID:        challenge.ChallengeID,
+				ChallengerID:       challenge.ChallengerID,
+				DefenderID:         challenge.DefenderID,
+				ChallengerPosition: challenger.Position,
+				DefenderPosition:   defender.Position,
+				Result:             action,
+				Score:              score,
+				ReporterID:         reportedBy,
+				Admin:              adminID != "",
+				ChallengeDate:      challenge.ChallengeDate,
+				ChallengeDeadline:  challenge.ChallengeDeadline,
+				ResolveDate:        time.Now(),
+			})
+	}
+
 	// if the challenger won (or the match was conceded or timed out), update the ranking
-	if action == "lost" || action == "forfeit" || action == "timed out" {
+	if (action == "lost" || action == "forfeit" || action == "timed out") && challenger.Position < defender.Position {
+		// a downward challenge doesn't change the ranking, whoever wins
+		result = fmt.Sprintf("Congratulations, %s/<@%s> has defeated %s/<@%s> and holds position %d!",
+			challenger.GameName, challenger.PlayerID,
+			defender.GameName, defender.PlayerID,
+			challenger.Position)
+	} else if action == "lost" || action == "forfeit" || action == "timed out" {
 		result = fmt.Sprintf("Congratulations, %s/<@%s> has advanced from position %d to position %d!",
 			challenger.GameName, challenger.PlayerID,
 			challenger.Position, defender.Position)
+		before := channel.positionSnapshot()
 		challenger.Position, defender.Position = defender.Position, challenger.Position
-		channel.fixPositions()
+		channel.fixPositions(before, "challenge", challenge.ChallengeID)
+	} else if action == "won" && challenger.Position < defender.Position {
+		result = fmt.Sprintf("%s/<@%s> defended against %s/<@%s>, positions don't change on challenges down",
+			defender.GameName, defender.PlayerID,
+			challenger.GameName, challenger.PlayerID)
 	} else if action == "won" {
 		result = fmt.Sprintf("Sorry, %s/<@%s>, better luck next time! %s/<@%s> holds position %d!",
 			challenger.GameName, challenger.PlayerID,
@@ -758,15 +1907,27 @@ func (channel *ChannelRankingData) ResolveChallenge(reporterID string, action st
 	}
 
 	// remove the challenge
-	for i := range channel.ActiveChallenges {
-		challenge := &channel.ActiveChallenges[i]
-		if challenge.ChallengerID == reporterID || challenge.DefenderID == reporterID {
-			channel.ActiveChallenges = append(channel.ActiveChallenges[:i], channel.ActiveChallenges[i+1:]...)
-			break
-		}
+	event := Event{
+		Type:         EventResultReported,
+		PlayerID:     challenge.ChallengerID,
+		OpponentID:   challenge.DefenderID,
+		ChallengeID:  challenge.ChallengeID,
+		ReporterID:   reportedBy,
+		Admin:        adminID != "",
+		ThreadID:     challenge.ThreadID,
+		GuildEventID: challenge.GuildEventID,
+		Result:       action,
+		Score:        score,
+	}
+	if action == "cancel" {
+		event.Type = EventChallengeCanceled
+		event.Result = ""
 	}
+	channel.addEvent(event)
+	challengeID = challenge.ChallengeID
+	channel.removeChallenge(challengeID)
 
-	return result, nil
+	return fmt.Sprintf("[%s] %s", challengeID, result), nil
 }
 
 // function that sets a player's availability
@@ -780,7 +1941,10 @@ func (channel *ChannelRankingData) SetPlayerStatus(playerID string, status strin
 		return errors.New("player not found")
 	}
 
-	player.Status = status
+	if player.Status != status {
+		player.Status = status
+		channel.addEvent(Event{Type: EventPlayerUpdated, PlayerID: playerID})
+	}
 	return nil
 }
 
@@ -795,7 +1959,10 @@ func (channel *ChannelRankingData) SetPlayerGameName(playerID string, gameName s
 		return errors.New("player not found")
 	}
 
-	player.GameName = gameName
+	if player.GameName != gameName {
+		player.GameName = gameName
+		channel.addEvent(Event{Type: EventPlayerUpdated, PlayerID: playerID})
+	}
 	return nil
 }
 
@@ -0,0 +1,141 @@
+package rankingdata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEntry records an admin action. Entries are kept separately from the
+// channels so they survive a tournament being deleted.
+type AuditEntry struct {
+	ChannelID string    `bson:"channel_id"`
+	ActorID   string    `bson:"actor_id"`
+	Source    string    `bson:"source"` // "discord" or "http"
+	Action    string    `bson:"action"`
+	TargetID  string    `bson:"target_id,omitempty"`
+	Before    string    `bson:"before,omitempty"`
+	After     string    `bson:"after,omitempty"`
+	Date      time.Time `bson:"date"`
+}
+
+// function that returns the name of the collection holding the audit log
+func (rankingData *RankingData) auditCollectionName() string {
+	return rankingData.conf.MongoCollectionName + "_audit"
+}
+
+// function that reads the audit log from a mongodb, oldest first
+func readAuditLog(ctx context.Context, collection *mongo.Collection) ([]AuditEntry, error) {
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// function that appends audit entries that haven't been written yet.
+// The audit log is never dropped, only appended to.
+func (rankingData *RankingData) writeAuditLog(ctx context.Context, collection *mongo.Collection) error {
+	rankingData.auditMutex.Lock()
+	defer rankingData.auditMutex.Unlock()
+
+	for len(rankingData.pendingAudit) > 0 {
+		if _, err := collection.InsertOne(ctx, rankingData.pendingAudit[0]); err != nil {
+			return err
+		}
+		rankingData.pendingAudit = rankingData.pendingAudit[1:]
+	}
+	return nil
+}
+
+// function that records an admin action, it is persisted on the next Write
+func (rankingData *RankingData) RecordAudit(entry AuditEntry) {
+	if entry.Date.IsZero() {
+		entry.Date = time.Now()
+	}
+
+	rankingData.auditMutex.Lock()
+	rankingData.AuditLog = append(rankingData.AuditLog, entry)
+	rankingData.pendingAudit = append(rankingData.pendingAudit, entry)
+	rankingData.auditMutex.Unlock()
+
+	// call the hook without holding the lock, it may be slow
+	if rankingData.AuditHook != nil {
+		rankingData.AuditHook(entry)
+	}
+}
+
+// function that describes an audit entry in Discord format
+func (entry *AuditEntry) String() string {
+	actor := fmt.Sprintf("<@%s>", entry.ActorID)
+	if entry.Source != "discord" {
+		actor = fmt.Sprintf("%s (%s)", entry.ActorID, entry.Source)
+	}
+	response := fmt.Sprintf("%s %s", actor, entry.Action)
+	if entry.TargetID != "" {
+		response += fmt.Sprintf(" <@%s>", entry.TargetID)
+	}
+	if entry.Before != "" || entry.After != "" {
+		response += fmt.Sprintf(": %s -> %s", valueOrNone(entry.Before), valueOrNone(entry.After))
+	}
+	return response
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// function that returns a Discord formatted string of a channel's audit log,
+// newest first, optionally only entries where the user is the actor or target
+func (rankingData *RankingData) PrintAudit(channelID string, userID string, limit int) (string, error) {
+	rankingData.auditMutex.Lock()
+	defer rankingData.auditMutex.Unlock()
+
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var response string
+	count := 0
+	for i := len(rankingData.AuditLog) - 1; i >= 0 && count < limit; i-- {
+		entry := &rankingData.AuditLog[i]
+		if entry.ChannelID != channelID {
+			continue
+		}
+		if userID != "" && entry.ActorID != userID && entry.TargetID != userID {
+			continue
+		}
+		response += fmt.Sprintf("%s %s\n", discordTime(entry.Date), entry.String())
+		count++
+	}
+	if count == 0 {
+		return "No admin actions recorded", nil
+	}
+	return "Admin actions:\n" + response, nil
+}
+
+// function that describes a player's position for the audit log, empty if
+// they aren't on the ladder
+func (channel *ChannelRankingData) DescribePosition(playerID string) string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s at position %d", player.GameName, player.Position)
+}
@@ -0,0 +1,206 @@
+package rankingdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Digest is a schedule for posting the standings with a summary of the period
+type Digest struct {
+	Frequency  string       `bson:"frequency"` // daily or weekly
+	Time       string       `bson:"time"`      // time of day as HH:MM
+	Timezone   string       `bson:"timezone"`
+	Weekday    time.Weekday `bson:"weekday,omitempty"` // day of weekly digests
+	LastPosted time.Time    `bson:"last_posted"`
+	MessageID  string       `bson:"message_id,omitempty"` // pinned message the digest is edited into
+}
+
+// function that returns the first time the digest is due after the given time
+func (digest *Digest) next(after time.Time) time.Time {
+	location, err := time.LoadLocation(digest.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	at, err := time.Parse("15:04", digest.Time)
+	if err != nil {
+		at = time.Time{}
+	}
+
+	local := after.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, location)
+	for !next.After(after) || (digest.Frequency == "weekly" && next.Weekday() != digest.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// function that describes the digest schedule
+func (digest *Digest) String() string {
+	if digest == nil {
+		return "off"
+	}
+	if digest.Frequency == "weekly" {
+		return fmt.Sprintf("weekly on %s at %s %s", digest.Weekday, digest.Time, digest.Timezone)
+	}
+	return fmt.Sprintf("daily at %s %s", digest.Time, digest.Timezone)
+}
+
+// function that sets or turns off the digest schedule, the first digest
+// covers the period from now
+func (channel *ChannelRankingData) SetDigest(frequency string, at string, timezone string, weekday string) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if frequency == "off" {
+		channel.Digest = nil
+		return "Standings digest turned off", nil
+	}
+	if frequency != "daily" && frequency != "weekly" {
+		return "", errors.New("frequency must be daily, weekly or off")
+	}
+	if _, err := time.Parse("15:04", at); err != nil {
+		return "", errors.New("invalid time, must be like 18:00")
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return "", fmt.Errorf("unknown timezone %s, use a name like Europe/Berlin", timezone)
+	}
+
+	digest := &Digest{
+		Frequency:  frequency,
+		Time:       at,
+		Timezone:   timezone,
+		LastPosted: time.Now(),
+	}
+	if frequency == "weekly" {
+		day, err := parseWeekday(weekday)
+		if err != nil {
+			return "", err
+		}
+		digest.Weekday = day
+	}
+	// keep editing the same pinned message
+	if channel.Digest != nil {
+		digest.MessageID = channel.Digest.MessageID
+	}
+	channel.Digest = digest
+
+	next := digest.next(digest.LastPosted)
+	return fmt.Sprintf("Standings digest will be posted %s, next %s", digest, discordTime(next)), nil
+}
+
+// function that returns the digest schedule of a channel
+func (channel *ChannelRankingData) DigestSchedule() string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.Digest.String()
+}
+
+// function that builds the digest if it is due, it returns the digest and
+// the message to edit, if any, the digest stays due until it is marked posted
+func (channel *ChannelRankingData) DueDigest(now time.Time) (string, string, bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	digest := channel.Digest
+	if digest == nil || digest.next(digest.LastPosted).After(now) {
+		return "", "", false
+	}
+
+	response := fmt.Sprintf("# Standings\nUpdated %s\n", discordTime(now))
+	standings, err := channel.printRankings()
+	if err != nil {
+		standings = err.Error() + "\n"
+	}
+	response += standings
+	response += fmt.Sprintf("## Since <t:%d:f>\n", digest.LastPosted.Unix())
+	response += channel.printResultsSince(digest.LastPosted)
+	response += channel.printMovesSince(digest.LastPosted)
+	return response, digest.MessageID, true
+}
+
+// function that marks the digest built at the given time as posted in a message,
+// the next digest covers the period from then
+func (channel *ChannelRankingData) MarkDigestPosted(posted time.Time, messageID string) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if channel.Digest != nil {
+		channel.Digest.LastPosted = posted
+		channel.Digest.MessageID = messageID
+	}
+}
+
+// private function that lists the results since a time, the channel must be locked
+func (channel *ChannelRankingData) printResultsSince(since time.Time) string {
+	response := ""
+	for _, result := range channel.ResultHistory {
+		if !result.ResolveDate.After(since) {
+			continue
+		}
+		response += fmt.Sprintf("[%s] %s vs %s (%s",
+			result.ChallengeID,
+			channel.playerName(result.ChallengerID),
+			channel.playerName(result.DefenderID),
+			result.Result)
+		if result.Score != "" {
+			response += " " + result.Score
+		}
+		response += ")\n"
+	}
+	if response == "" {
+		return "No matches played\n"
+	}
+	return "Results:\n" + response
+}
+
+// private function that lists each player's net move since a time, the channel must be locked
+func (channel *ChannelRankingData) printMovesSince(since time.Time) string {
+	order := []string{}
+	first := map[string]int{}
+	last := map[string]int{}
+	for _, change := range channel.PositionHistory {
+		if !change.Date.After(since) {
+			continue
+		}
+		if _, ok := first[change.PlayerID]; !ok {
+			order = append(order, change.PlayerID)
+			first[change.PlayerID] = change.OldPosition
+		}
+		last[change.PlayerID] = change.NewPosition
+	}
+
+	moves := []string{}
+	for _, playerID := range order {
+		from, to := first[playerID], last[playerID]
+		switch {
+		case from == to:
+			continue
+		case from == 0:
+			moves = append(moves, fmt.Sprintf("%s joined at #%d", channel.playerName(playerID), to))
+		case to == 0:
+			moves = append(moves, fmt.Sprintf("%s left the ladder", channel.playerName(playerID)))
+		default:
+			moves = append(moves, fmt.Sprintf("%s #%d → #%d", channel.playerName(playerID), from, to))
+		}
+	}
+	if len(moves) == 0 {
+		return "No position changes\n"
+	}
+	return "Position changes:\n" + strings.Join(moves, "\n") + "\n"
+}
+
+// function that parses a weekday name like monday
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return time.Sunday, errors.New("weekly digests need a weekday, like monday")
+}
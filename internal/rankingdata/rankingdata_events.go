@@ -0,0 +1,55 @@
+package rankingdata
+
+import "time"
+
+// event types emitted when a channel changes
+const (
+	EventPlayerRegistered   = "player_registered"
+	EventPlayerUnregistered = "player_unregistered"
+	EventChallengeStarted   = "challenge_started"
+	EventChallengeCanceled  = "challenge_canceled"
+	EventResultReported     = "result_reported"
+	EventPositionChanged    = "position_changed"
+	EventTimesProposed      = "times_proposed"
+	EventMatchScheduled     = "match_scheduled"
+	EventPlayerUpdated      = "player_updated" // game name or status changed
+)
+
+// Event describes a change to a channel. Events are queued on the channel
+// until they are collected with TakeEvents, they are not persisted.
+type Event struct {
+	Type        string    `json:"type"`
+	ChannelID   string    `json:"channel_id"`
+	Date        time.Time `json:"date"`
+	PlayerID    string    `json:"player_id,omitempty"`
+	OpponentID  string    `json:"opponent_id,omitempty"`
+	ReporterID  string    `json:"reporter_id,omitempty"`
+	Admin       bool      `json:"admin,omitempty"` // the reporter is an admin, not one of the players
+	ChallengeID string    `json:"challenge_id,omitempty"`
+	ThreadID    string    `json:"thread_id,omitempty"`
+	Result      string    `json:"result,omitempty"`
+	Score       string    `json:"score,omitempty"`
+	OldPosition int       `json:"old_position,omitempty"`
+	NewPosition int       `json:"new_position,omitempty"`
+	// the guild scheduled event of the challenge, only used inside the bot
+	GuildEventID string `json:"-"`
+}
+
+// private function that queues an event, the channel must be locked
+func (channel *ChannelRankingData) addEvent(event Event) {
+	event.ChannelID = channel.ChannelID
+	if event.Date.IsZero() {
+		event.Date = time.Now()
+	}
+	channel.events = append(channel.events, event)
+}
+
+// function that returns and clears the events queued since the last call
+func (channel *ChannelRankingData) TakeEvents() []Event {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	events := channel.events
+	channel.events = nil
+	return events
+}
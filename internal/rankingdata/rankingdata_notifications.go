@@ -0,0 +1,87 @@
+package rankingdata
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// kinds of direct message notifications, players get all of them unless muted
+const (
+	NotifyChallenged = "challenged"
+	NotifyResults    = "results"
+	NotifyDeadlines  = "deadlines"
+	NotifyPositions  = "positions"
+)
+
+// NotificationKinds lists every kind of notification
+var NotificationKinds = []string{NotifyChallenged, NotifyResults, NotifyDeadlines, NotifyPositions}
+
+// function that turns a kind of notification on or off for a player
+func (channel *ChannelRankingData) SetPlayerNotification(playerID string, kind string, enabled bool) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if !slices.Contains(NotificationKinds, kind) {
+		return errors.New("invalid notification kind")
+	}
+	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return errors.New("player not found")
+	}
+
+	player.MutedNotifications = slices.DeleteFunc(player.MutedNotifications, func(muted string) bool {
+		return muted == kind
+	})
+	if !enabled {
+		player.MutedNotifications = append(player.MutedNotifications, kind)
+	}
+	return nil
+}
+
+// function that checks if a player wants a kind of notification
+func (channel *ChannelRankingData) WantsNotification(playerID string, kind string) bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	player, err := channel.findPlayer(playerID)
+	if err != nil {
+		return false
+	}
+	return !slices.Contains(player.MutedNotifications, kind)
+}
+
+// function that lists the notifications a player gets
+func (player Player) Notifications() string {
+	enabled := []string{}
+	for _, kind := range NotificationKinds {
+		if !slices.Contains(player.MutedNotifications, kind) {
+			enabled = append(enabled, kind)
+		}
+	}
+	if len(enabled) == 0 {
+		return "none"
+	}
+	return strings.Join(enabled, ", ")
+}
+
+// function that returns the challenges whose deadline is within the lead time
+// that haven't been warned about yet, and marks them as warned
+func (channel *ChannelRankingData) TakeDeadlineWarnings(now time.Time, lead time.Duration) []Challenge {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	due := []Challenge{}
+	for i := range channel.ActiveChallenges {
+		challenge := &channel.ActiveChallenges[i]
+		if challenge.DeadlineWarned {
+			continue
+		}
+		if challenge.ChallengeDeadline.After(now) && challenge.ChallengeDeadline.Sub(now) <= lead {
+			challenge.DeadlineWarned = true
+			due = append(due, *challenge)
+		}
+	}
+	return due
+}
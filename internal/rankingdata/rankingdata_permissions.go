@@ -0,0 +1,114 @@
+package rankingdata
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// capabilities that can be granted to admins and moderators
+const (
+	CapManagePlayers   = "manage_players"   // register, unregister, move and edit other players
+	CapResolveDisputes = "resolve_disputes" // challenge and report results for other players
+	CapEditSettings    = "edit_settings"    // change system settings and delete the tournament
+)
+
+// the capabilities of each role, admins can do everything
+var moderatorCapabilities = []string{CapManagePlayers, CapResolveDisputes}
+
+// Member describes who is asking for a capability. GuildManager is set when
+// their guild permissions let them manage the channel, which always grants admin rights.
+type Member struct {
+	UserID       string
+	RoleIDs      []string
+	GuildManager bool
+}
+
+// function that checks if a member has a capability in the channel
+func (channel *ChannelRankingData) HasCapability(member Member, capability string) bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	// admins can do everything
+	if channel.OpenAdmin || member.GuildManager || slices.Contains(channel.Admins, member.UserID) {
+		return true
+	}
+	for _, role := range member.RoleIDs {
+		if slices.Contains(channel.AdminRoles, role) {
+			return true
+		}
+	}
+
+	// moderators can do some things
+	if !slices.Contains(moderatorCapabilities, capability) {
+		return false
+	}
+	for _, role := range member.RoleIDs {
+		if slices.Contains(channel.ModeratorRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// function that returns the role list for "admin" or "moderator", the channel must be locked
+func (channel *ChannelRankingData) roleList(kind string) (*[]string, error) {
+	switch kind {
+	case "admin":
+		return &channel.AdminRoles, nil
+	case "moderator":
+		return &channel.ModeratorRoles, nil
+	}
+	return nil, errors.New("invalid role kind, must be admin or moderator")
+}
+
+// function that grants admin or moderator rights to a Discord role
+func (channel *ChannelRankingData) AddRole(kind string, roleID string) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	roles, err := channel.roleList(kind)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(*roles, roleID) {
+		return errors.New("role already has " + kind + " rights")
+	}
+	*roles = append(*roles, roleID)
+	return nil
+}
+
+// function that takes admin or moderator rights away from a Discord role
+func (channel *ChannelRankingData) RemoveRole(kind string, roleID string) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	roles, err := channel.roleList(kind)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(*roles, roleID)
+	if i < 0 {
+		return errors.New("role does not have " + kind + " rights")
+	}
+	*roles = slices.Delete(*roles, i, i+1)
+	return nil
+}
+
+// function that sets whether everyone has admin rights
+func (channel *ChannelRankingData) SetOpenAdmin(open bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.OpenAdmin = open
+}
+
+// function that formats a list of role IDs as Discord role mentions
+func RoleMentions(roleIDs []string) string {
+	mentions := []string{}
+	for _, roleID := range roleIDs {
+		mentions = append(mentions, fmt.Sprintf("<@&%s>", roleID))
+	}
+	return strings.Join(mentions, " ")
+}
@@ -0,0 +1,184 @@
+package rankingdata
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// RankRole is a Discord role given to the players in a range of positions,
+// or in a pyramid tier when Tier is set
+type RankRole struct {
+	RoleID      string `bson:"role_id"`
+	MinPosition int    `bson:"min_position,omitempty"`
+	MaxPosition int    `bson:"max_position,omitempty"`
+	Tier        int    `bson:"tier,omitempty"`
+}
+
+// function that checks if a position earns the role
+func (role *RankRole) matches(position int) bool {
+	if role.Tier > 0 {
+		return tierFromPos(position) == role.Tier
+	}
+	return position >= role.MinPosition && position <= role.MaxPosition
+}
+
+// function that describes which positions earn the role
+func (role *RankRole) String() string {
+	switch {
+	case role.Tier > 0:
+		return fmt.Sprintf("<@&%s>: tier %d", role.RoleID, role.Tier)
+	case role.MinPosition == role.MaxPosition:
+		return fmt.Sprintf("<@&%s>: position %d", role.RoleID, role.MinPosition)
+	}
+	return fmt.Sprintf("<@&%s>: positions %d-%d", role.RoleID, role.MinPosition, role.MaxPosition)
+}
+
+// function that adds a rank role, replacing any existing mapping for the same role
+func (channel *ChannelRankingData) SetRankRole(role RankRole) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if role.RoleID == "" {
+		return errors.New("role not specified")
+	}
+	if role.Tier < 0 || (role.Tier > 0 && (role.MinPosition != 0 || role.MaxPosition != 0)) {
+		return errors.New("give either a tier or a range of positions")
+	}
+	if role.Tier == 0 && (role.MinPosition < 1 || role.MaxPosition < role.MinPosition) {
+		return errors.New("invalid positions, must be like 1 or 1-10")
+	}
+
+	i := slices.IndexFunc(channel.RankRoles, func(r RankRole) bool { return r.RoleID == role.RoleID })
+	if i >= 0 {
+		channel.RankRoles[i] = role
+	} else {
+		channel.RankRoles = append(channel.RankRoles, role)
+	}
+	return nil
+}
+
+// function that removes a rank role
+func (channel *ChannelRankingData) RemoveRankRole(roleID string) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	i := slices.IndexFunc(channel.RankRoles, func(r RankRole) bool { return r.RoleID == roleID })
+	if i < 0 {
+		return errors.New("role is not a rank role")
+	}
+	channel.RankRoles = slices.Delete(channel.RankRoles, i, i+1)
+	return nil
+}
+
+// function that returns a copy of the rank roles
+func (channel *ChannelRankingData) GetRankRoles() []RankRole {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return slices.Clone(channel.RankRoles)
+}
+
+// function that returns a Discord formatted list of the rank roles
+func (channel *ChannelRankingData) PrintRankRoles() string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	if len(channel.RankRoles) == 0 {
+		return "No rank roles"
+	}
+	response := "Rank roles:\n"
+	for i := range channel.RankRoles {
+		response += fmt.Sprintf("  %s\n", channel.RankRoles[i].String())
+	}
+	return response
+}
+
+// function that returns the rank roles each player should have, by player ID.
+// Players without any rank role are left out.
+func (channel *ChannelRankingData) DesiredRankRoles() map[string][]string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	desired := map[string][]string{}
+	for _, player := range channel.RankedPlayers {
+		for i := range channel.RankRoles {
+			role := &channel.RankRoles[i]
+			if role.matches(player.Position) {
+				desired[player.PlayerID] = append(desired[player.PlayerID], role.RoleID)
+			}
+		}
+	}
+	return desired
+}
+
+// function that checks if a role is one of the channel's rank roles
+func (channel *ChannelRankingData) HasRankRole(roleID string) bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return slices.ContainsFunc(channel.RankRoles, func(r RankRole) bool { return r.RoleID == roleID })
+}
+
+// function that checks if a member is a ranked player of the channel
+func (channel *ChannelRankingData) IsPlayer(playerID string) bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	_, err := channel.findPlayer(playerID)
+	return err == nil
+}
+
+// function that returns the roles the bot has given to members and may take
+// away again, including roles that are no longer rank roles
+func (channel *ChannelRankingData) GrantedRoleIDs() []string {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	roleIDs := make([]string, 0, len(channel.GrantedRoles))
+	for roleID := range channel.GrantedRoles {
+		roleIDs = append(roleIDs, roleID)
+	}
+	slices.Sort(roleIDs)
+	return roleIDs
+}
+
+// function that checks if the bot gave a member a role
+func (channel *ChannelRankingData) HasGranted(roleID string, userID string) bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return slices.Contains(channel.GrantedRoles[roleID], userID)
+}
+
+// function that remembers the bot gave a member a role, or took it away
+func (channel *ChannelRankingData) RecordGrant(roleID string, userID string, granted bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	members := slices.DeleteFunc(channel.GrantedRoles[roleID], func(id string) bool { return id == userID })
+	if granted {
+		members = append(members, userID)
+	}
+	if len(members) == 0 {
+		delete(channel.GrantedRoles, roleID)
+		return
+	}
+	if channel.GrantedRoles == nil {
+		channel.GrantedRoles = map[string][]string{}
+	}
+	channel.GrantedRoles[roleID] = members
+}
+
+// function that returns the channel a role is a rank role of, if any
+func (rankingData *RankingData) RankRoleChannel(roleID string) (string, bool) {
+	rankingData.mutex.Lock()
+	defer rankingData.mutex.Unlock()
+
+	for _, channel := range rankingData.Channels {
+		if channel.HasRankRole(roleID) {
+			return channel.ChannelID, true
+		}
+	}
+	return "", false
+}
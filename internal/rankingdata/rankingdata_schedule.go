@@ -0,0 +1,149 @@
+package rankingdata
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maximum number of times that can be proposed at once
+const maxProposedTimes = 5
+
+// function that proposes times to play a challenge, replacing any earlier proposal
+func (channel *ChannelRankingData) ProposeTimes(playerID string, challengeID string, times []time.Time) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenge, err := channel.findPlayerChallenge(playerID, challengeID)
+	if err != nil {
+		return "", err
+	}
+	if len(times) == 0 || len(times) > maxProposedTimes {
+		return "", fmt.Errorf("please propose between 1 and %d times", maxProposedTimes)
+	}
+	now := time.Now()
+	for _, t := range times {
+		if !t.After(now) {
+			return "", errors.New("proposed times must be in the future")
+		}
+		if t.After(challenge.ChallengeDeadline) {
+			return "", fmt.Errorf("proposed times must be before the deadline, %s", discordTime(challenge.ChallengeDeadline))
+		}
+	}
+
+	challenge.ProposedTimes = times
+	challenge.ProposedBy = playerID
+	channel.addEvent(Event{
+		Type:        EventTimesProposed,
+		PlayerID:    playerID,
+		OpponentID:  challenge.opponent(playerID),
+		ChallengeID: challenge.ChallengeID,
+		ThreadID:    challenge.ThreadID,
+	})
+
+	response := fmt.Sprintf("[%s] %s proposed:\n", challenge.ChallengeID, channel.playerName(playerID))
+	for i, t := range times {
+		response += fmt.Sprintf("  %d. %s\n", i+1, discordTime(t))
+	}
+	response += fmt.Sprintf("%s can accept one of them.", channel.playerName(challenge.opponent(playerID)))
+	return response, nil
+}
+
+// function that accepts one of the times proposed by the other player
+func (channel *ChannelRankingData) AcceptTime(playerID string, challengeID string, proposed time.Time) (string, error) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenge, err := channel.findPlayerChallenge(playerID, challengeID)
+	if err != nil {
+		return "", err
+	}
+	if len(challenge.ProposedTimes) == 0 {
+		return "", errors.New("no times have been proposed")
+	}
+	if challenge.ProposedBy == playerID {
+		return "", errors.New("the other player has to accept your proposal")
+	}
+	// times are matched to the second, a newer proposal replaces the old times
+	index := slices.IndexFunc(challenge.ProposedTimes, func(t time.Time) bool { return t.Unix() == proposed.Unix() })
+	if index < 0 {
+		return "", errors.New("that time is no longer proposed, pick one of the latest proposed times")
+	}
+
+	challenge.ScheduledTime = challenge.ProposedTimes[index]
+	challenge.ProposedTimes = nil
+	challenge.ProposedBy = ""
+	challenge.ReminderSent = false
+	channel.addEvent(Event{
+		Type:         EventMatchScheduled,
+		PlayerID:     playerID,
+		OpponentID:   challenge.opponent(playerID),
+		ChallengeID:  challenge.ChallengeID,
+		ThreadID:     challenge.ThreadID,
+		GuildEventID: challenge.GuildEventID,
+	})
+
+	return fmt.Sprintf("[%s] %s vs %s is scheduled for %s",
+		challenge.ChallengeID,
+		channel.playerName(challenge.ChallengerID),
+		channel.playerName(challenge.DefenderID),
+		discordTime(challenge.ScheduledTime)), nil
+}
+
+// function that remembers the guild scheduled event made for a challenge
+func (channel *ChannelRankingData) SetChallengeGuildEvent(challengeID string, eventID string) error {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	challenge, err := channel.findChallengeByID(challengeID)
+	if err != nil {
+		return err
+	}
+	challenge.GuildEventID = eventID
+	return nil
+}
+
+// function that returns the scheduled challenges starting within the lead time
+// that haven't been reminded yet, and marks them as reminded
+func (channel *ChannelRankingData) TakeDueReminders(now time.Time, lead time.Duration) []Challenge {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	due := []Challenge{}
+	for i := range channel.ActiveChallenges {
+		challenge := &channel.ActiveChallenges[i]
+		if challenge.ScheduledTime.IsZero() || challenge.ReminderSent {
+			continue
+		}
+		if challenge.ScheduledTime.After(now) && challenge.ScheduledTime.Sub(now) <= lead {
+			challenge.ReminderSent = true
+			due = append(due, *challenge)
+		}
+	}
+	return due
+}
+
+// function that sets whether agreed match times are added as guild scheduled events
+func (channel *ChannelRankingData) SetScheduledEvents(enabled bool) {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	channel.ScheduledEvents = enabled
+}
+
+// function that returns whether agreed match times are added as guild scheduled events
+func (channel *ChannelRankingData) HasScheduledEvents() bool {
+	channel.mutex.Lock()
+	defer channel.mutex.Unlock()
+
+	return channel.ScheduledEvents
+}
+
+// function that returns the other player in a challenge
+func (challenge *Challenge) opponent(playerID string) string {
+	if challenge.ChallengerID == playerID {
+		return challenge.DefenderID
+	}
+	return challenge.ChallengerID
+}
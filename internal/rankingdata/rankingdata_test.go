@@ -1,7 +1,9 @@
 package rankingdata
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/magiconair/properties/assert"
 )
@@ -199,3 +201,897 @@ func TestMovePlayer(t *testing.T) {
 		t.Errorf("Error moving player: %s", err)
 	}
 }
+
+func TestStartChallengeCooldowns(t *testing.T) {
+	now := time.Now()
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "open",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RematchCooldown:      24 * time.Hour,
+		DefenseImmunity:      2 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+			{PlayerID: "3456", GameName: "u3456", Status: "active", Position: 4},
+			{PlayerID: "7890", GameName: "u7890", Status: "active", Position: 5},
+		},
+		ResultHistory: []ResultHistory{
+			// 5678 lost to 1234 a few hours ago
+			{ChallengerID: "5678", DefenderID: "1234", Result: "won",
+				ChallengeDate: now.Add(-48 * time.Hour), ResolveDate: now.Add(-5 * time.Hour)},
+			// 3456 took 9012's old spot a moment ago
+			{ChallengerID: "3456", DefenderID: "9012", Result: "lost",
+				ChallengeDate: now.Add(-24 * time.Hour), ResolveDate: now.Add(-10 * time.Minute)},
+		},
+	}
+
+	// the rematch cooldown blocks the same pairing
+	if _, err := channel.StartChallenge("5678", "1234"); err == nil {
+		t.Errorf("Expected rematch cooldown error")
+	}
+
+	// the defense immunity blocks a fresh defender, even against a challenger it hasn't played
+	if _, err := channel.StartChallenge("7890", "9012"); err == nil || !strings.Contains(err.Error(), "recently defended") {
+		t.Errorf("Expected defense immunity error, got %v", err)
+	}
+
+	// disabling the cooldown allows the rematch
+	if err := channel.SetRematchCooldown(0); err != nil {
+		t.Errorf("Error setting rematch cooldown: %s", err)
+	}
+	if _, err := channel.StartChallenge("5678", "1234"); err != nil {
+		t.Errorf("Error starting challenge: %s", err)
+	}
+
+	// other pairings are unaffected
+	if _, err := channel.StartChallenge("7890", "3456"); err != nil {
+		t.Errorf("Error starting challenge: %s", err)
+	}
+	assert.Equal(t, len(channel.ActiveChallenges), 2)
+}
+
+func TestCheckReach(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		ChallengeMode: "ladder",
+		RankedPlayers: []Player{
+			{PlayerID: "p1", GameName: "u1", Status: "active", Position: 1},
+			{PlayerID: "p2", GameName: "u2", Status: "active", Position: 2},
+			{PlayerID: "p3", GameName: "u3", Status: "inactive", Position: 3},
+			{PlayerID: "p4", GameName: "u4", Status: "active", Position: 4},
+			{PlayerID: "p5", GameName: "u5", Status: "active", Position: 5},
+			{PlayerID: "p6", GameName: "u6", Status: "active", Position: 6},
+			{PlayerID: "p7", GameName: "u7", Status: "active", Position: 7},
+			{PlayerID: "p8", GameName: "u8", Status: "active", Position: 8},
+			{PlayerID: "p9", GameName: "u9", Status: "active", Position: 9},
+			{PlayerID: "p10", GameName: "u10", Status: "active", Position: 10},
+		},
+	}
+	player := func(id string) *Player {
+		p, err := channel.findPlayer(id)
+		if err != nil {
+			t.Fatalf("Error finding player: %s", err)
+		}
+		return p
+	}
+
+	testCases := []struct {
+		name       string
+		setup      func()
+		challenger string
+		defender   string
+		allowed    bool
+	}{
+		{"ladder next up", func() {}, "p5", "p4", true},
+		{"ladder two up", func() {}, "p6", "p4", false},
+		{"ladder downward", func() {}, "p4", "p5", false},
+		{"ladder over inactive", func() {}, "p4", "p2", false},
+		{"ladder skip inactive", func() { channel.SkipInactive = true }, "p4", "p2", true},
+		{"ladder three up", func() { channel.MaxPositionsUp = 3 }, "p7", "p4", true},
+		{"ladder four up", func() { channel.MaxPositionsUp = 3 }, "p8", "p4", false},
+		{"pyramid one tier", func() { channel.ChallengeMode = "pyramid" }, "p7", "p4", true},
+		{"pyramid two tiers", func() { channel.ChallengeMode = "pyramid" }, "p7", "p2", false},
+		{"pyramid two tiers allowed", func() { channel.ChallengeMode = "pyramid"; channel.MaxTiersUp = 2 }, "p7", "p2", true},
+		{"open anywhere", func() { channel.ChallengeMode = "open" }, "p10", "p1", true},
+		{"open percent", func() { channel.ChallengeMode = "open"; channel.MaxPercentUp = 30 }, "p10", "p7", true},
+		{"open percent too far", func() { channel.ChallengeMode = "open"; channel.MaxPercentUp = 30 }, "p10", "p6", false},
+		{"open downward", func() { channel.ChallengeMode = "open"; channel.AllowDownward = true }, "p1", "p10", true},
+	}
+	for _, tc := range testCases {
+		channel.ChallengeMode = "ladder"
+		channel.MaxPositionsUp = 0
+		channel.MaxTiersUp = 0
+		channel.MaxPercentUp = 0
+		channel.SkipInactive = false
+		channel.AllowDownward = false
+		tc.setup()
+
+		err := channel.checkReach(player(tc.challenger), player(tc.defender))
+		if tc.allowed && err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.name, err)
+		} else if !tc.allowed && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	// the ladder error names the direction of the challenge
+	channel.ChallengeMode = "ladder"
+	channel.AllowDownward = true
+	err := channel.checkReach(player("p4"), player("p6"))
+	assert.Equal(t, err.Error(), "challenger may only challenge the next person down")
+}
+
+func TestConcurrentChallenges(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "open",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+			{PlayerID: "3456", GameName: "u3456", Status: "active", Position: 4},
+		},
+	}
+
+	// by default a player can only be in one challenge
+	if _, err := channel.StartChallenge("3456", "1234"); err != nil {
+		t.Errorf("Error starting challenge: %s", err)
+	}
+	if _, err := channel.StartChallenge("3456", "5678"); err == nil {
+		t.Errorf("Expected challenger to be unavailable")
+	}
+	if _, err := channel.StartChallenge("9012", "1234"); err == nil {
+		t.Errorf("Expected defender to be unavailable")
+	}
+
+	// with limits configured, more challenges are allowed up to the limit
+	if err := channel.SetMaxOutgoing(2); err != nil {
+		t.Errorf("Error setting max outgoing: %s", err)
+	}
+	if err := channel.SetMaxIncoming(1); err != nil {
+		t.Errorf("Error setting max incoming: %s", err)
+	}
+	if _, err := channel.StartChallenge("3456", "5678"); err != nil {
+		t.Errorf("Error starting challenge: %s", err)
+	}
+	if _, err := channel.StartChallenge("3456", "9012"); err == nil {
+		t.Errorf("Expected challenger to be at the outgoing limit")
+	}
+	if _, err := channel.StartChallenge("9012", "1234"); err == nil {
+		t.Errorf("Expected defender to be at the incoming limit")
+	}
+	assert.Equal(t, len(channel.ActiveChallenges), 2)
+	assert.Equal(t, channel.ActiveChallenges[0].ChallengeID, "1")
+	assert.Equal(t, channel.ActiveChallenges[1].ChallengeID, "2")
+
+	// a player in several challenges must say which one they mean
+	if _, err := channel.ResolveChallenge("3456", "", "cancel", ""); err == nil {
+		t.Errorf("Expected an ambiguous challenge error")
+	}
+	if _, err := channel.ResolveChallenge("1234", "2", "won", ""); err == nil {
+		t.Errorf("Expected an error resolving someone else's challenge")
+	}
+	if _, err := channel.ResolveChallenge("3456", "1", "cancel", ""); err != nil {
+		t.Errorf("Error canceling challenge: %s", err)
+	}
+
+	// with only one challenge left, the ID is optional
+	if _, err := channel.ResolveChallenge("5678", "", "lost", ""); err != nil {
+		t.Errorf("Error resolving challenge: %s", err)
+	}
+	assert.Equal(t, len(channel.ActiveChallenges), 0)
+	assert.Equal(t, len(channel.ResultHistory), 1)
+	assert.Equal(t, channel.ResultHistory[0].ChallengeID, "2")
+	assert.Equal(t, channel.ResultHistory[0].ReporterID, "5678")
+
+	// resolved matches can still be looked up, canceled ones cannot
+	if _, err := channel.PrintMatch("2"); err != nil {
+		t.Errorf("Error printing match: %s", err)
+	}
+	if _, err := channel.PrintMatch("1"); err == nil {
+		t.Errorf("Expected canceled match to be missing")
+	}
+
+	if player, err := channel.findPlayer("3456"); err != nil {
+		t.Errorf("Error finding player: %s", err)
+	} else {
+		assert.Equal(t, player.Position, 2)
+	}
+}
+
+func TestPlayerStats(t *testing.T) {
+	now := time.Now()
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		ChallengeMode: "ladder",
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1, BestPosition: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2, BestPosition: 1},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+		},
+		ResultHistory: []ResultHistory{
+			{ChallengerID: "5678", DefenderID: "1234", Result: "lost", ResolveDate: now.Add(-5 * time.Hour)},
+			{ChallengerID: "9012", DefenderID: "5678", Result: "won", ResolveDate: now.Add(-4 * time.Hour)},
+			{ChallengerID: "1234", DefenderID: "5678", Result: "forfeit", ResolveDate: now.Add(-3 * time.Hour)},
+			{ChallengerID: "9012", DefenderID: "5678", Result: "lost", ResolveDate: now.Add(-2 * time.Hour)},
+			{ChallengerID: "9012", DefenderID: "1234", Result: "won", ResolveDate: now.Add(-1 * time.Hour)},
+		},
+	}
+
+	stats, err := channel.PlayerStats("5678")
+	if err != nil {
+		t.Fatalf("Error getting stats: %s", err)
+	}
+	assert.Equal(t, stats.Tier, 2)
+	assert.Equal(t, stats.BestPosition, 1)
+	assert.Equal(t, stats.ChallengerWins, 1)
+	assert.Equal(t, stats.ChallengerLosses, 0)
+	assert.Equal(t, stats.DefenderWins, 1)
+	assert.Equal(t, stats.DefenderLosses, 1)
+	assert.Equal(t, stats.DefenderForfeits, 1)
+	assert.Equal(t, stats.Streak, -2)
+	assert.Equal(t, stats.LastMatch, now.Add(-2*time.Hour))
+
+	// players without a stored best position fall back to their current one
+	stats, err = channel.PlayerStats("9012")
+	if err != nil {
+		t.Fatalf("Error getting stats: %s", err)
+	}
+	assert.Equal(t, stats.BestPosition, 3)
+	assert.Equal(t, stats.ChallengerWins, 1)
+	assert.Equal(t, stats.ChallengerLosses, 2)
+	assert.Equal(t, stats.Streak, -1)
+
+	if _, err := channel.PlayerStats("1111"); err == nil {
+		t.Errorf("Expected an error for a missing player")
+	}
+}
+
+func TestPrintHeadToHead(t *testing.T) {
+	now := time.Now()
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		ChallengeMode: "ladder",
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+		},
+		ResultHistory: []ResultHistory{
+			{ChallengeID: "1", ChallengerID: "5678", DefenderID: "1234", Result: "won",
+				ResolveDate: now.Add(-5 * time.Hour)},
+			{ChallengeID: "2", ChallengerID: "9012", DefenderID: "5678", Result: "won",
+				ResolveDate: now.Add(-4 * time.Hour)},
+			{ChallengeID: "3", ChallengerID: "5678", DefenderID: "1234", Result: "lost", Score: "3-2",
+				ChallengerPosition: 2, DefenderPosition: 1, ResolveDate: now.Add(-3 * time.Hour)},
+			{ChallengeID: "4", ChallengerID: "1234", DefenderID: "5678", Result: "lost",
+				ChallengerPosition: 2, DefenderPosition: 1, ResolveDate: now.Add(-2 * time.Hour)},
+		},
+	}
+
+	response, err := channel.PrintHeadToHead("1234", "5678", 2)
+	if err != nil {
+		t.Fatalf("Error printing head to head: %s", err)
+	}
+	assert.Equal(t, strings.Contains(response, "u1234/<@1234>: 2 wins"), true)
+	assert.Equal(t, strings.Contains(response, "u5678/<@5678>: 1 wins"), true)
+	assert.Equal(t, strings.Contains(response, "Last 2 meetings"), true)
+	assert.Equal(t, strings.Contains(response, "[4]"), true)
+	assert.Equal(t, strings.Contains(response, "[3]"), true)
+	assert.Equal(t, strings.Contains(response, "[1]"), false)
+	assert.Equal(t, strings.Contains(response, "3-2, #2 -> #1"), true)
+
+	// players that never met get a friendly message
+	response, err = channel.PrintHeadToHead("1234", "9012", 5)
+	if err != nil {
+		t.Fatalf("Error printing head to head: %s", err)
+	}
+	assert.Equal(t, strings.Contains(response, "have not played"), true)
+
+	if _, err := channel.PrintHeadToHead("1234", "1234", 5); err == nil {
+		t.Errorf("Expected an error comparing a player to themselves")
+	}
+}
+
+func TestPositionHistory(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+		},
+	}
+
+	// joining records a single change for the new player
+	if _, err := channel.AddPlayer("3456", "u3456"); err != nil {
+		t.Fatalf("Error adding player: %s", err)
+	}
+	assert.Equal(t, len(channel.PositionHistory), 1)
+	assert.Equal(t, channel.PositionHistory[0].PlayerID, "3456")
+	assert.Equal(t, channel.PositionHistory[0].OldPosition, 0)
+	assert.Equal(t, channel.PositionHistory[0].NewPosition, 4)
+
+	// a won challenge records both players with the match that caused it
+	if _, err := channel.StartChallenge("3456", "9012"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	if _, err := channel.ResolveChallenge("9012", "", "lost", ""); err != nil {
+		t.Fatalf("Error resolving challenge: %s", err)
+	}
+	assert.Equal(t, len(channel.PositionHistory), 3)
+	for _, change := range channel.PositionHistory[1:] {
+		assert.Equal(t, change.Reason, "challenge")
+		assert.Equal(t, change.ChallengeID, "1")
+	}
+
+	// the same changes are queued as events for webhooks
+	events := channel.TakeEvents()
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, types, []string{EventPlayerRegistered, EventPositionChanged, EventChallengeStarted,
+		EventPositionChanged, EventPositionChanged, EventResultReported})
+	assert.Equal(t, events[5].ChannelID, "1234")
+	assert.Equal(t, len(channel.TakeEvents()), 0)
+
+	// leaving records the departed player and everyone who moved up
+	if _, err := channel.RemovePlayer("1234"); err != nil {
+		t.Fatalf("Error removing player: %s", err)
+	}
+	assert.Equal(t, len(channel.PositionHistory), 7)
+
+	response, err := channel.PrintTimeline("3456", 10)
+	if err != nil {
+		t.Fatalf("Error printing timeline: %s", err)
+	}
+	assert.Equal(t, strings.Contains(response, "joined at #4"), true)
+	assert.Equal(t, strings.Contains(response, "#4 -> #3 (match [1])"), true)
+	assert.Equal(t, strings.Contains(response, "#3 -> #2 (a player left)"), true)
+}
+
+func TestPrintAudit(t *testing.T) {
+	rankingData := &RankingData{}
+	mirrored := []AuditEntry{}
+	rankingData.AuditHook = func(entry AuditEntry) { mirrored = append(mirrored, entry) }
+
+	rankingData.RecordAudit(AuditEntry{ChannelID: "1234", ActorID: "1", Source: "discord", Action: "move",
+		TargetID: "5678", Before: "u5678 at position 3", After: "u5678 at position 1"})
+	rankingData.RecordAudit(AuditEntry{ChannelID: "1234", ActorID: "1", Source: "discord", Action: "system_settings",
+		Before: "mode: ladder", After: "mode: pyramid"})
+	rankingData.RecordAudit(AuditEntry{ChannelID: "9999", ActorID: "1", Source: "discord", Action: "delete_tournament"})
+	rankingData.RecordAudit(AuditEntry{ChannelID: "1234", ActorID: "admin-api", Source: "http", Action: "unregister",
+		TargetID: "9012", Before: "u9012 at position 2"})
+	assert.Equal(t, len(mirrored), 4)
+	assert.Equal(t, len(rankingData.pendingAudit), 4)
+
+	// newest first, only for the channel
+	response, err := rankingData.PrintAudit("1234", "", 10)
+	if err != nil {
+		t.Fatalf("Error printing audit log: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(response), "\n")
+	assert.Equal(t, len(lines), 4)
+	assert.Equal(t, strings.HasSuffix(lines[1], "admin-api (http) unregister <@9012>: u9012 at position 2 -> none"), true)
+	assert.Equal(t, strings.HasSuffix(lines[3], "<@1> move <@5678>: u5678 at position 3 -> u5678 at position 1"), true)
+
+	// filtered by actor or target, and limited
+	response, _ = rankingData.PrintAudit("1234", "5678", 10)
+	assert.Equal(t, strings.Count(response, "\n"), 2)
+	response, _ = rankingData.PrintAudit("1234", "1", 1)
+	assert.Equal(t, strings.Contains(response, "system_settings"), true)
+	assert.Equal(t, strings.Contains(response, "move"), false)
+	response, _ = rankingData.PrintAudit("1234", "4444", 10)
+	assert.Equal(t, response, "No admin actions recorded")
+}
+
+func TestHasCapability(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:      "1234",
+		Admins:         []string{"1"},
+		AdminRoles:     []string{"100"},
+		ModeratorRoles: []string{"200"},
+	}
+
+	testCases := []struct {
+		name       string
+		member     Member
+		capability string
+		expected   bool
+	}{
+		{"listed admin", Member{UserID: "1"}, CapEditSettings, true},
+		{"admin role", Member{UserID: "2", RoleIDs: []string{"300", "100"}}, CapEditSettings, true},
+		{"guild manager", Member{UserID: "2", GuildManager: true}, CapEditSettings, true},
+		{"moderator manages players", Member{UserID: "2", RoleIDs: []string{"200"}}, CapManagePlayers, true},
+		{"moderator resolves disputes", Member{UserID: "2", RoleIDs: []string{"200"}}, CapResolveDisputes, true},
+		{"moderator can't edit settings", Member{UserID: "2", RoleIDs: []string{"200"}}, CapEditSettings, false},
+		{"player", Member{UserID: "2", RoleIDs: []string{"300"}}, CapManagePlayers, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, channel.HasCapability(tc.member, tc.capability), tc.expected, tc.name)
+	}
+
+	// an empty admin list no longer means everyone is an admin
+	open := &ChannelRankingData{ChannelID: "5678", AdminsMigrated: true}
+	assert.Equal(t, open.HasCapability(Member{UserID: "2"}, CapManagePlayers), false)
+	open.SetOpenAdmin(true)
+	assert.Equal(t, open.HasCapability(Member{UserID: "2"}, CapEditSettings), true)
+
+	// channels saved with an empty admin list keep everyone an admin, once
+	saved := &ChannelRankingData{ChannelID: "9012"}
+	saved.migrateOpenAdmin()
+	assert.Equal(t, saved.HasCapability(Member{UserID: "2"}, CapEditSettings), true)
+	saved.SetOpenAdmin(false)
+	saved.migrateOpenAdmin()
+	assert.Equal(t, saved.OpenAdmin, false)
+	listed := &ChannelRankingData{ChannelID: "3456", Admins: []string{"1"}}
+	listed.migrateOpenAdmin()
+	assert.Equal(t, listed.OpenAdmin, false)
+
+	// roles can be granted and taken away
+	if err := open.AddRole("moderator", "200"); err != nil {
+		t.Fatalf("Error adding role: %s", err)
+	}
+	assert.Equal(t, open.AddRole("moderator", "200") != nil, true)
+	assert.Equal(t, open.AddRole("owner", "200") != nil, true)
+	if err := open.RemoveRole("moderator", "200"); err != nil {
+		t.Fatalf("Error removing role: %s", err)
+	}
+	assert.Equal(t, open.RemoveRole("moderator", "200") != nil, true)
+	assert.Equal(t, len(open.ModeratorRoles), 0)
+}
+
+func TestDesiredRankRoles(t *testing.T) {
+	channel := &ChannelRankingData{ChannelID: "1234", ChallengeMode: "pyramid"}
+	for i := 1; i <= 6; i++ {
+		channel.RankedPlayers = append(channel.RankedPlayers,
+			Player{PlayerID: string(rune('a' + i - 1)), Status: "active", Position: i})
+	}
+
+	// roles can be given by position or by tier, and overlap
+	if err := channel.SetRankRole(RankRole{RoleID: "champion", MinPosition: 1, MaxPosition: 1}); err != nil {
+		t.Fatalf("Error setting rank role: %s", err)
+	}
+	if err := channel.SetRankRole(RankRole{RoleID: "top3", MinPosition: 1, MaxPosition: 3}); err != nil {
+		t.Fatalf("Error setting rank role: %s", err)
+	}
+	if err := channel.SetRankRole(RankRole{RoleID: "tier3", Tier: 3}); err != nil {
+		t.Fatalf("Error setting rank role: %s", err)
+	}
+	assert.Equal(t, channel.SetRankRole(RankRole{RoleID: "bad", MinPosition: 3, MaxPosition: 1}) != nil, true)
+	assert.Equal(t, channel.SetRankRole(RankRole{RoleID: "bad", Tier: 2, MinPosition: 1, MaxPosition: 1}) != nil, true)
+
+	desired := channel.DesiredRankRoles()
+	assert.Equal(t, desired["a"], []string{"champion", "top3"})
+	assert.Equal(t, desired["b"], []string{"top3"})
+	assert.Equal(t, desired["c"], []string{"top3"})
+	assert.Equal(t, desired["d"], []string{"tier3"})
+	assert.Equal(t, desired["f"], []string{"tier3"})
+	assert.Equal(t, len(desired), 6)
+
+	// moving players moves their roles, and setting a role again replaces it
+	if _, err := channel.MovePlayer("d", 1); err != nil {
+		t.Fatalf("Error moving player: %s", err)
+	}
+	if err := channel.SetRankRole(RankRole{RoleID: "top3", MinPosition: 1, MaxPosition: 2}); err != nil {
+		t.Fatalf("Error setting rank role: %s", err)
+	}
+	if err := channel.RemoveRankRole("tier3"); err != nil {
+		t.Fatalf("Error removing rank role: %s", err)
+	}
+	desired = channel.DesiredRankRoles()
+	assert.Equal(t, desired["d"], []string{"champion", "top3"})
+	assert.Equal(t, desired["a"], []string{"top3"})
+	assert.Equal(t, len(desired), 2)
+	assert.Equal(t, len(channel.GetRankRoles()), 2)
+}
+
+func TestChallengeThread(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+			{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+		},
+	}
+	if _, err := channel.StartChallenge("5678", "1234"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	if _, err := channel.StartChallenge("9012", "5678"); err == nil {
+		t.Fatalf("Expected the defender to be busy")
+	}
+	assert.Equal(t, channel.SetChallengeThread("9", "42") != nil, true)
+	if err := channel.SetChallengeThread("1", "42"); err != nil {
+		t.Fatalf("Error setting thread: %s", err)
+	}
+	challenge, err := channel.FindChallenge("1")
+	if err != nil {
+		t.Fatalf("Error finding challenge: %s", err)
+	}
+	assert.Equal(t, challenge.ThreadID, "42")
+	response, _ := channel.PrintChallenges()
+	assert.Equal(t, strings.Contains(response, "in <#42>"), true)
+
+	// the thread is passed on when the challenge ends so it can be archived
+	channel.TakeEvents()
+	if _, err := channel.RemovePlayer("1234"); err != nil {
+		t.Fatalf("Error removing player: %s", err)
+	}
+	threads := []string{}
+	for _, event := range channel.TakeEvents() {
+		if event.Type == EventChallengeCanceled {
+			threads = append(threads, event.ThreadID)
+		}
+	}
+	assert.Equal(t, threads, []string{"42"})
+}
+
+func TestScheduleChallenge(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+		},
+	}
+	if _, err := channel.StartChallenge("5678", "1234"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	now := time.Now()
+	soon := now.Add(20 * time.Minute)
+	later := now.Add(48 * time.Hour)
+
+	_, err := channel.ProposeTimes("5678", "1", []time.Time{now.Add(-time.Hour)})
+	assert.Equal(t, err != nil, true)
+	_, err = channel.ProposeTimes("5678", "1", []time.Time{now.Add(30 * 24 * time.Hour)})
+	assert.Equal(t, err != nil, true)
+	if _, err := channel.ProposeTimes("5678", "1", []time.Time{later, soon}); err != nil {
+		t.Fatalf("Error proposing times: %s", err)
+	}
+
+	// only the other player can accept, and only a time that is still proposed
+	_, err = channel.AcceptTime("5678", "1", soon)
+	assert.Equal(t, err != nil, true)
+	_, err = channel.AcceptTime("1234", "1", now)
+	assert.Equal(t, err != nil, true)
+
+	// a newer proposal replaces the old times, so old buttons don't book them
+	if _, err := channel.ProposeTimes("5678", "1", []time.Time{soon}); err != nil {
+		t.Fatalf("Error proposing times: %s", err)
+	}
+	_, err = channel.AcceptTime("1234", "1", later)
+	assert.Equal(t, err != nil, true)
+	if _, err := channel.AcceptTime("1234", "1", soon); err != nil {
+		t.Fatalf("Error accepting time: %s", err)
+	}
+	challenge, _ := channel.FindChallenge("1")
+	assert.Equal(t, challenge.ScheduledTime.Equal(soon), true)
+	assert.Equal(t, len(challenge.ProposedTimes), 0)
+
+	types := []string{}
+	for _, event := range channel.TakeEvents() {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, types, []string{EventChallengeStarted, EventTimesProposed, EventTimesProposed, EventMatchScheduled})
+
+	// players are reminded once
+	assert.Equal(t, len(channel.TakeDueReminders(now, 10*time.Minute)), 0)
+	assert.Equal(t, len(channel.TakeDueReminders(now, 30*time.Minute)), 1)
+	assert.Equal(t, len(channel.TakeDueReminders(now, 30*time.Minute)), 0)
+
+	assert.Equal(t, channel.HasScheduledEvents(), false)
+	channel.SetScheduledEvents(true)
+	assert.Equal(t, channel.HasScheduledEvents(), true)
+}
+
+func TestDigest(t *testing.T) {
+	digest := &Digest{Frequency: "daily", Time: "18:00", Timezone: "UTC"}
+	after := time.Date(2024, 6, 5, 19, 0, 0, 0, time.UTC) // a wednesday
+	assert.Equal(t, digest.next(after), time.Date(2024, 6, 6, 18, 0, 0, 0, time.UTC))
+	digest.Frequency = "weekly"
+	digest.Weekday = time.Monday
+	assert.Equal(t, digest.next(after), time.Date(2024, 6, 10, 18, 0, 0, 0, time.UTC))
+
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		ChallengeMode: "ladder",
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+		},
+	}
+	_, err := channel.SetDigest("weekly", "18:00", "UTC", "")
+	assert.Equal(t, err != nil, true)
+	_, err = channel.SetDigest("daily", "6pm", "UTC", "")
+	assert.Equal(t, err != nil, true)
+	if _, err := channel.SetDigest("daily", "18:00", "UTC", ""); err != nil {
+		t.Fatalf("Error setting digest: %s", err)
+	}
+	start := channel.Digest.LastPosted
+	channel.ResultHistory = append(channel.ResultHistory, ResultHistory{
+		ChallengeID: "1", ChallengerID: "5678", DefenderID: "1234", Result: "lost", ResolveDate: start.Add(time.Minute),
+	})
+	channel.PositionHistory = append(channel.PositionHistory,
+		PositionChange{PlayerID: "5678", OldPosition: 2, NewPosition: 1, Date: start.Add(time.Minute)},
+		PositionChange{PlayerID: "1234", OldPosition: 1, NewPosition: 2, Date: start.Add(time.Minute)},
+	)
+
+	_, _, due := channel.DueDigest(start.Add(time.Minute))
+	assert.Equal(t, due, false)
+	now := start.Add(25 * time.Hour)
+	response, _, due := channel.DueDigest(now)
+	assert.Equal(t, due, true)
+	assert.Equal(t, strings.Contains(response, "[1] u5678/<@5678> vs u1234/<@1234> (lost)"), true)
+	assert.Equal(t, strings.Contains(response, "u5678/<@5678> #2 → #1"), true)
+	// a digest that failed to post stays due
+	_, _, due = channel.DueDigest(now)
+	assert.Equal(t, due, true)
+	channel.MarkDigestPosted(now, "42")
+	_, _, due = channel.DueDigest(now)
+	assert.Equal(t, due, false)
+
+	// the pinned message is kept when the schedule changes
+	channel.SetDigest("weekly", "09:00", "UTC", "Monday")
+	assert.Equal(t, channel.Digest.MessageID, "42")
+	assert.Equal(t, channel.DigestSchedule(), "weekly on Monday at 09:00 UTC")
+}
+
+func TestNotifications(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+		},
+	}
+	assert.Equal(t, channel.WantsNotification("1234", NotifyResults), true)
+	assert.Equal(t, channel.WantsNotification("9999", NotifyResults), false)
+	assert.Equal(t, channel.SetPlayerNotification("1234", "spam", false) != nil, true)
+	if err := channel.SetPlayerNotification("1234", NotifyResults, false); err != nil {
+		t.Fatalf("Error setting notification: %s", err)
+	}
+	// muting twice is harmless
+	channel.SetPlayerNotification("1234", NotifyResults, false)
+	assert.Equal(t, channel.WantsNotification("1234", NotifyResults), false)
+	player, _ := channel.FindPlayer("1234")
+	assert.Equal(t, player.MutedNotifications, []string{NotifyResults})
+	assert.Equal(t, player.Notifications(), "challenged, deadlines, positions")
+	channel.SetPlayerNotification("1234", NotifyResults, true)
+	assert.Equal(t, channel.WantsNotification("1234", NotifyResults), true)
+
+	// the result event says who reported it
+	if _, err := channel.StartChallenge("5678", "1234"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	now := time.Now()
+	assert.Equal(t, len(channel.TakeDeadlineWarnings(now, 24*time.Hour)), 0)
+	assert.Equal(t, len(channel.TakeDeadlineWarnings(now.Add(6*24*time.Hour+time.Hour), 24*time.Hour)), 1)
+	assert.Equal(t, len(channel.TakeDeadlineWarnings(now.Add(6*24*time.Hour+time.Hour), 24*time.Hour)), 0)
+	if _, err := channel.ResolveChallenge("1234", "1", "won", ""); err != nil {
+		t.Fatalf("Error resolving challenge: %s", err)
+	}
+	reporters := []string{}
+	for _, event := range channel.TakeEvents() {
+		if event.Type == EventResultReported {
+			reporters = append(reporters, event.ReporterID)
+		}
+	}
+	assert.Equal(t, reporters, []string{"1234"})
+}
+
+func TestRemovePlayerChallenges(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		ChallengeMode: "open",
+		RankedPlayers: []Player{
+			{PlayerID: "a", GameName: "ua", Status: "active", Position: 1},
+			{PlayerID: "x", GameName: "ux", Status: "active", Position: 2},
+			{PlayerID: "b", GameName: "ub", Status: "active", Position: 3},
+			{PlayerID: "y", GameName: "uy", Status: "active", Position: 4},
+		},
+		// the removed player's challenges are next to each other
+		ActiveChallenges: []Challenge{
+			{ChallengeID: "1", ChallengerID: "b", DefenderID: "a", ThreadID: "t1"},
+			{ChallengeID: "2", ChallengerID: "y", DefenderID: "a", ThreadID: "t2"},
+			{ChallengeID: "3", ChallengerID: "y", DefenderID: "x", ThreadID: "t3"},
+		},
+	}
+	if _, err := channel.RemovePlayer("a"); err != nil {
+		t.Fatalf("Error removing player: %s", err)
+	}
+
+	remaining := []string{}
+	for _, challenge := range channel.ActiveChallenges {
+		remaining = append(remaining, challenge.ChallengeID)
+	}
+	assert.Equal(t, remaining, []string{"3"})
+
+	threads := []string{}
+	for _, event := range channel.TakeEvents() {
+		if event.Type == EventChallengeCanceled {
+			threads = append(threads, event.ThreadID)
+		}
+	}
+	assert.Equal(t, threads, []string{"t1", "t2"})
+}
+
+func TestMatchIDs(t *testing.T) {
+	newChannel := func(channelID string, lastID int) *ChannelRankingData {
+		return &ChannelRankingData{
+			ChannelID:            channelID,
+			ChallengeMode:        "ladder",
+			ChallengeTimeoutDays: 7 * 24 * time.Hour,
+			LastChallengeID:      lastID,
+			RankedPlayers: []Player{
+				{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+				{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+				{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+			},
+		}
+	}
+
+	// IDs count up per channel and carry on from the stored counter
+	first := newChannel("1", 0)
+	second := newChannel("2", 41)
+	first.StartChallenge("5678", "1234")
+	second.StartChallenge("5678", "1234")
+	assert.Equal(t, first.ActiveChallenges[0].ChallengeID, "1")
+	assert.Equal(t, second.ActiveChallenges[0].ChallengeID, "42")
+
+	// IDs aren't reused after a challenge ends
+	if _, err := first.ResolveChallenge("1234", "1", "won", "3-1"); err != nil {
+		t.Fatalf("Error resolving challenge: %s", err)
+	}
+	if _, err := first.StartChallenge("9012", "5678"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	assert.Equal(t, first.ActiveChallenges[0].ChallengeID, "2")
+
+	// /match finds active and resolved matches by ID
+	active, err := first.PrintMatch("2")
+	if err != nil {
+		t.Fatalf("Error printing match: %s", err)
+	}
+	assert.Equal(t, strings.Contains(active, "Match [2]:"), true)
+	assert.Equal(t, strings.Contains(active, "status: active"), true)
+	resolved, err := first.PrintMatch("1")
+	if err != nil {
+		t.Fatalf("Error printing match: %s", err)
+	}
+	assert.Equal(t, strings.Contains(resolved, "status: resolved (defender won)"), true)
+	assert.Equal(t, strings.Contains(resolved, "score: 3-1"), true)
+	_, err = first.PrintMatch("3")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestMigrateTimeout(t *testing.T) {
+	// channels made before the fix stored the default timeout as 7 nanoseconds
+	channel := ChannelRankingData{ChallengeTimeoutDays: 7}
+	channel.migrateTimeout()
+	assert.Equal(t, channel.ChallengeTimeoutDays, 7*24*time.Hour)
+	assert.Equal(t, channel.TimeoutDays(), 7)
+
+	// timeouts set with /system_settings were already stored in days
+	channel = ChannelRankingData{ChallengeTimeoutDays: 3 * 24 * time.Hour}
+	channel.migrateTimeout()
+	assert.Equal(t, channel.ChallengeTimeoutDays, 3*24*time.Hour)
+}
+
+func TestGrantedRoles(t *testing.T) {
+	rankingData := &RankingData{Channels: []*ChannelRankingData{
+		{ChannelID: "1", RankedPlayers: []Player{{PlayerID: "a", Position: 1}}},
+		{ChannelID: "2"},
+	}}
+	channel := rankingData.Channels[0]
+	if err := channel.SetRankRole(RankRole{RoleID: "champion", MinPosition: 1, MaxPosition: 1}); err != nil {
+		t.Fatalf("Error setting rank role: %s", err)
+	}
+
+	// rank roles belong to one ladder
+	channelID, ok := rankingData.RankRoleChannel("champion")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, channelID, "1")
+	_, ok = rankingData.RankRoleChannel("other")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, channel.IsPlayer("a"), true)
+	assert.Equal(t, channel.IsPlayer("b"), false)
+
+	// grants are remembered until the role is taken away, even after the role is retired
+	channel.RecordGrant("champion", "a", true)
+	channel.RecordGrant("champion", "b", true)
+	channel.RecordGrant("champion", "b", true)
+	assert.Equal(t, channel.GrantedRoles["champion"], []string{"a", "b"})
+	if err := channel.RemoveRankRole("champion"); err != nil {
+		t.Fatalf("Error removing rank role: %s", err)
+	}
+	assert.Equal(t, channel.GrantedRoleIDs(), []string{"champion"})
+	assert.Equal(t, channel.HasGranted("champion", "a"), true)
+	channel.RecordGrant("champion", "a", false)
+	channel.RecordGrant("champion", "b", false)
+	assert.Equal(t, channel.HasGranted("champion", "a"), false)
+	assert.Equal(t, len(channel.GrantedRoleIDs()), 0)
+}
+
+func TestPlayerUpdatedEvents(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:     "1234",
+		RankedPlayers: []Player{{PlayerID: "a", GameName: "ua", Status: "active", Position: 1}},
+	}
+
+	// the leaderboard shows names and statuses, so changing them is an event
+	if err := channel.SetPlayerGameName("a", "new"); err != nil {
+		t.Fatalf("Error setting game name: %s", err)
+	}
+	if err := channel.SetPlayerStatus("a", "active"); err != nil {
+		t.Fatalf("Error setting status: %s", err)
+	}
+	if err := channel.SetPlayerStatus("a", "inactive"); err != nil {
+		t.Fatalf("Error setting status: %s", err)
+	}
+	events := channel.TakeEvents()
+	assert.Equal(t, len(events), 2)
+	assert.Equal(t, events[0].Type, EventPlayerUpdated)
+	assert.Equal(t, events[0].PlayerID, "a")
+}
+
+func TestResolveChallengeByID(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 7 * 24 * time.Hour,
+		RankedPlayers: []Player{
+			{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+			{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+		},
+	}
+	if _, err := channel.StartChallenge("5678", "1234"); err != nil {
+		t.Fatalf("Error starting challenge: %s", err)
+	}
+	channel.TakeEvents()
+
+	// the result counts as the defender's report, but the event and history name the admin
+	if _, err := channel.ResolveChallengeByID("admin", "1", "lost", "0-2"); err != nil {
+		t.Fatalf("Error resolving challenge: %s", err)
+	}
+	events := channel.TakeEvents()
+	assert.Equal(t, events[len(events)-1].Type, EventResultReported)
+	assert.Equal(t, events[len(events)-1].ReporterID, "admin")
+	assert.Equal(t, events[len(events)-1].Admin, true)
+	assert.Equal(t, channel.ResultHistory[0].ReporterID, "admin")
+	assert.Equal(t, channel.ResultHistory[0].Admin, true)
+	match, _ := channel.PrintMatch("1")
+	assert.Equal(t, strings.Contains(match, "reported by: an admin"), true)
+	player, _ := channel.FindPlayer("5678")
+	assert.Equal(t, player.Position, 1)
+}
+
+func TestPrintSettings(t *testing.T) {
+	channel := &ChannelRankingData{
+		ChannelID:            "1234",
+		ChallengeMode:        "ladder",
+		ChallengeTimeoutDays: 3 * 24 * time.Hour,
+		Admins:               []string{"1234"},
+		AdminRoles:           []string{"99"},
+	}
+	settings, err := channel.PrintSettings()
+	if err != nil {
+		t.Fatalf("Error printing settings: %s", err)
+	}
+	assert.Equal(t, strings.Contains(settings, "  timeout: 3 (days)\n"), true)
+	assert.Equal(t, strings.Contains(settings, "  admins: <@1234> \n"), true)
+	assert.Equal(t, strings.Contains(settings, "  admin roles: <@&99>\n"), true)
+	assert.Equal(t, strings.Contains(settings, "Challenge rules (mode: ladder):\n"), true)
+}
@@ -0,0 +1,208 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/rankingdata"
+)
+
+const (
+	// maximum number of attempts before a delivery is given up on
+	maxAttempts = 5
+	// number of deliveries kept in the delivery log
+	logSize = 100
+)
+
+// Delivery records an attempt to send an event to a webhook
+type Delivery struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Date       time.Time `json:"date"`
+}
+
+// job is a pending delivery of one event to one webhook
+type job struct {
+	id      string
+	hook    config.Webhook
+	event   string
+	payload []byte
+	attempt int
+}
+
+type Dispatcher struct {
+	Client *http.Client
+	// RetryDelay is the delay before the first retry, doubled for each later one
+	RetryDelay time.Duration
+	hooks      []config.Webhook
+	queue      chan job
+	done       chan struct{}
+	wait       sync.WaitGroup
+	mutex      sync.Mutex
+	log        []Delivery
+	nextID     int
+}
+
+// NewDispatcher creates a new Dispatcher sending events to the configured webhooks
+func NewDispatcher(hooks []config.Webhook) *Dispatcher {
+	return &Dispatcher{
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		RetryDelay: 5 * time.Second,
+		hooks:      hooks,
+		queue:      make(chan job, 256),
+		done:       make(chan struct{}),
+	}
+}
+
+// function that computes the signature sent in the X-Ladder-Signature header
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// function that checks a signature computed by Sign
+func Verify(secret string, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// Start delivering events in the background
+func (dispatcher *Dispatcher) Start() {
+	dispatcher.wait.Add(1)
+	go func() {
+		defer dispatcher.wait.Done()
+		for {
+			select {
+			case j := <-dispatcher.queue:
+				dispatcher.deliver(j)
+			case <-dispatcher.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop delivering events, anything still queued is dropped
+func (dispatcher *Dispatcher) Stop() {
+	close(dispatcher.done)
+	dispatcher.wait.Wait()
+}
+
+// function that queues events for every webhook subscribed to them
+func (dispatcher *Dispatcher) Send(events []rankingdata.Event) {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("error encoding webhook event", "type", event.Type, "error", err)
+			continue
+		}
+		for _, hook := range dispatcher.hooks {
+			if len(hook.Events) > 0 && !slices.Contains(hook.Events, event.Type) {
+				continue
+			}
+			dispatcher.mutex.Lock()
+			dispatcher.nextID++
+			id := strconv.Itoa(dispatcher.nextID)
+			dispatcher.mutex.Unlock()
+			dispatcher.enqueue(job{id: id, hook: hook, event: event.Type, payload: payload, attempt: 1})
+		}
+	}
+}
+
+// function that adds a job to the queue without blocking the caller
+func (dispatcher *Dispatcher) enqueue(j job) {
+	select {
+	case dispatcher.queue <- j:
+	case <-dispatcher.done:
+	default:
+		dispatcher.record(j, 0, fmt.Errorf("queue full, delivery dropped"))
+	}
+}
+
+// function that sends a job, scheduling a retry if it fails
+func (dispatcher *Dispatcher) deliver(j job) {
+	statusCode, err := dispatcher.post(j)
+	dispatcher.record(j, statusCode, err)
+	if err != nil {
+		slog.Warn("webhook delivery failed", "delivery", j.id, "url", j.hook.URL,
+			"event", j.event, "attempt", j.attempt, "error", err)
+	}
+	if err == nil || j.attempt >= maxAttempts {
+		return
+	}
+
+	delay := dispatcher.RetryDelay << (j.attempt - 1)
+	j.attempt++
+	time.AfterFunc(delay, func() { dispatcher.enqueue(j) })
+}
+
+// function that posts a job's payload to its webhook
+func (dispatcher *Dispatcher) post(j job) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, j.hook.URL, bytes.NewReader(j.payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Ladder-Event", j.event)
+	request.Header.Set("X-Ladder-Delivery", j.id)
+	if j.hook.Secret != "" {
+		request.Header.Set("X-Ladder-Signature", Sign(j.hook.Secret, j.payload))
+	}
+
+	response, err := dispatcher.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// function that adds an attempt to the delivery log
+func (dispatcher *Dispatcher) record(j job, statusCode int, err error) {
+	delivery := Delivery{
+		ID:         j.id,
+		URL:        j.hook.URL,
+		Event:      j.event,
+		Attempt:    j.attempt,
+		StatusCode: statusCode,
+		Date:       time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+	dispatcher.log = append(dispatcher.log, delivery)
+	if len(dispatcher.log) > logSize {
+		dispatcher.log = dispatcher.log[len(dispatcher.log)-logSize:]
+	}
+}
+
+// function that returns the delivery log, newest first
+func (dispatcher *Dispatcher) Deliveries() []Delivery {
+	dispatcher.mutex.Lock()
+	defer dispatcher.mutex.Unlock()
+
+	deliveries := slices.Clone(dispatcher.log)
+	slices.Reverse(deliveries)
+	return deliveries
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/rankingdata"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func TestSign(t *testing.T) {
+	payload := []byte(`{"type":"challenge_started"}`)
+	signature := Sign("secret", payload)
+	assert.Equal(t, Verify("secret", payload, signature), true)
+	assert.Equal(t, Verify("other", payload, signature), false)
+	assert.Equal(t, Verify("secret", []byte(`{}`), signature), false)
+}
+
+func TestDispatcher(t *testing.T) {
+	// the stand-in fails the first request it sees, then accepts everything
+	var mutex sync.Mutex
+	requests := 0
+	received := make(chan rankingdata.Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		first := requests == 1
+		mutex.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("secret", body, r.Header.Get("X-Ladder-Signature")) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event rankingdata.Event
+		json.Unmarshal(body, &event)
+		assert.Equal(t, r.Header.Get("X-Ladder-Event"), event.Type)
+		received <- event
+	}))
+	defer server.Close()
+
+	dispatcher := NewDispatcher([]config.Webhook{
+		{URL: server.URL, Secret: "secret", Events: []string{rankingdata.EventResultReported}},
+	})
+	dispatcher.RetryDelay = time.Millisecond
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// only subscribed events are sent
+	dispatcher.Send([]rankingdata.Event{
+		{Type: rankingdata.EventChallengeStarted, ChannelID: "1234", ChallengeID: "1"},
+		{Type: rankingdata.EventResultReported, ChannelID: "1234", ChallengeID: "1", Result: "won"},
+	})
+
+	select {
+	case event := <-received:
+		assert.Equal(t, event.ChallengeID, "1")
+		assert.Equal(t, event.Result, "won")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Event was not delivered")
+	}
+
+	// the failed attempt and the retry are both logged, newest first
+	deliveries := dispatcher.Deliveries()
+	for start := time.Now(); len(deliveries) < 2 && time.Since(start) < 5*time.Second; {
+		time.Sleep(time.Millisecond)
+		deliveries = dispatcher.Deliveries()
+	}
+	assert.Equal(t, len(deliveries), 2)
+	assert.Equal(t, deliveries[0].Attempt, 2)
+	assert.Equal(t, deliveries[0].StatusCode, http.StatusOK)
+	assert.Equal(t, deliveries[1].Attempt, 1)
+	assert.Equal(t, deliveries[1].StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, deliveries[0].ID, deliveries[1].ID)
+}
@@ -0,0 +1,99 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/metrics"
+	"discord_ladder_bot/internal/rankingdata"
+	"discord_ladder_bot/internal/webhooks"
+)
+
+type WebServer struct {
+	RankingData *rankingdata.RankingData
+	// OnChange is called after the admin API changes a channel
+	OnChange func(*rankingdata.ChannelRankingData)
+	// Webhooks, when set, exposes the webhook delivery log on the admin API
+	Webhooks   *webhooks.Dispatcher
+	server     *http.Server
+	mux        *http.ServeMux
+	adminToken string
+}
+
+// NewWebServer creates a new WebServer instance serving the ranking data
+func NewWebServer(conf *config.Config, rankingData *rankingdata.RankingData) *WebServer {
+	web := &WebServer{
+		RankingData: rankingData,
+		mux:         http.NewServeMux(),
+		adminToken:  conf.AdminAPIToken,
+	}
+	web.server = &http.Server{
+		Addr:              conf.HTTPAddress,
+		Handler:           web.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	web.addAPIRoutes()
+	web.addDashboardRoutes()
+	web.addAdminRoutes()
+	web.mux.Handle("GET /metrics", metrics.Handler())
+
+	return web
+}
+
+// Handler returns the HTTP handler for all routes
+func (web *WebServer) Handler() http.Handler {
+	return web.mux
+}
+
+// Start listening in the background
+func (web *WebServer) Start() error {
+	listener, err := net.Listen("tcp", web.server.Addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("web server listening", "address", listener.Addr().String())
+
+	go func() {
+		err := web.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("web server error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Stop the web server
+func (web *WebServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	web.server.Shutdown(ctx)
+}
+
+// function that writes a value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// function that writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// function that finds the channel named in the request path
+func (web *WebServer) findChannel(w http.ResponseWriter, r *http.Request) (*rankingdata.ChannelRankingData, bool) {
+	channel, err := web.RankingData.FindChannel(r.PathValue("channel"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	return channel, true
+}
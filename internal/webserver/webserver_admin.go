@@ -0,0 +1,242 @@
+package webserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"discord_ladder_bot/internal/rankingdata"
+)
+
+// admin API request bodies
+
+type addPlayerRequest struct {
+	PlayerID string `json:"player_id"`
+	GameName string `json:"game_name"`
+}
+
+type movePlayerRequest struct {
+	Position int `json:"position"`
+}
+
+type settingsRequest struct {
+	Mode        *string `json:"mode"`
+	TimeoutDays *int    `json:"timeout_days"`
+}
+
+type resolveRequest struct {
+	Result string `json:"result"`
+	Score  string `json:"score"`
+}
+
+// adminHandler is an admin API handler, returning a message describing the change
+type adminHandler func(*rankingdata.ChannelRankingData, *http.Request, *rankingdata.AuditEntry) (string, error)
+
+// function that registers the token authenticated admin API routes
+func (web *WebServer) addAdminRoutes() {
+	web.mux.HandleFunc("POST /api/admin/channels/{channel}/players", web.admin(handleAdminAddPlayer))
+	web.mux.HandleFunc("DELETE /api/admin/channels/{channel}/players/{player}", web.admin(handleAdminRemovePlayer))
+	web.mux.HandleFunc("POST /api/admin/channels/{channel}/players/{player}/move", web.admin(handleAdminMovePlayer))
+	web.mux.HandleFunc("PATCH /api/admin/channels/{channel}/settings", web.admin(handleAdminSettings))
+	web.mux.HandleFunc("POST /api/admin/channels/{channel}/challenges/{challenge}/resolve", web.admin(handleAdminResolve))
+	web.mux.HandleFunc("GET /api/admin/webhooks/deliveries", web.handleWebhookDeliveries)
+}
+
+// function that checks the bearer token of a request
+func (web *WebServer) authorized(r *http.Request) bool {
+	if web.adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(web.adminToken)) == 1
+}
+
+// function that wraps an admin handler with authentication, auditing and saving
+func (web *WebServer) admin(handler adminHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !web.authorized(r) {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing admin token"))
+			return
+		}
+
+		channel, ok := web.findChannel(w, r)
+		if !ok {
+			return
+		}
+
+		// callers may say who they are, otherwise the token holder is recorded
+		actor := r.Header.Get("X-Audit-Actor")
+		if actor == "" {
+			actor = "admin-api"
+		}
+		entry := rankingdata.AuditEntry{
+			ChannelID: channel.ChannelID,
+			ActorID:   actor,
+			Source:    "http",
+		}
+
+		message, err := handler(channel, r, &entry)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		web.RankingData.RecordAudit(entry)
+		if web.OnChange != nil {
+			web.OnChange(channel)
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": message})
+	}
+}
+
+// function that lists recent webhook deliveries, newest first
+func (web *WebServer) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !web.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing admin token"))
+		return
+	}
+	if web.Webhooks == nil {
+		writeJSON(w, http.StatusOK, []any{})
+		return
+	}
+	writeJSON(w, http.StatusOK, web.Webhooks.Deliveries())
+}
+
+// function that decodes a JSON request body
+func decodeBody(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func handleAdminAddPlayer(c *rankingdata.ChannelRankingData, r *http.Request, entry *rankingdata.AuditEntry) (string, error) {
+	var body addPlayerRequest
+	if err := decodeBody(r, &body); err != nil {
+		return "", err
+	}
+	if body.PlayerID == "" {
+		return "", errors.New("player_id is required")
+	}
+	if body.GameName == "" {
+		body.GameName = "Unknown"
+	}
+
+	message, err := c.AddPlayer(body.PlayerID, body.GameName)
+	if err != nil {
+		return "", err
+	}
+	entry.Action = "register"
+	entry.TargetID = body.PlayerID
+	entry.After = c.DescribePosition(body.PlayerID)
+	return message, nil
+}
+
+func handleAdminRemovePlayer(c *rankingdata.ChannelRankingData, r *http.Request, entry *rankingdata.AuditEntry) (string, error) {
+	playerID := r.PathValue("player")
+	before := c.DescribePosition(playerID)
+
+	message, err := c.RemovePlayer(playerID)
+	if err != nil {
+		return "", err
+	}
+	entry.Action = "unregister"
+	entry.TargetID = playerID
+	entry.Before = before
+	return message, nil
+}
+
+func handleAdminMovePlayer(c *rankingdata.ChannelRankingData, r *http.Request, entry *rankingdata.AuditEntry) (string, error) {
+	var body movePlayerRequest
+	if err := decodeBody(r, &body); err != nil {
+		return "", err
+	}
+	playerID := r.PathValue("player")
+	before := c.DescribePosition(playerID)
+
+	message, err := c.MovePlayer(playerID, body.Position)
+	if err != nil {
+		return "", err
+	}
+	entry.Action = "move"
+	entry.TargetID = playerID
+	entry.Before = before
+	entry.After = c.DescribePosition(playerID)
+	return message, nil
+}
+
+func handleAdminSettings(c *rankingdata.ChannelRankingData, r *http.Request, entry *rankingdata.AuditEntry) (string, error) {
+	var body settingsRequest
+	if err := decodeBody(r, &body); err != nil {
+		return "", err
+	}
+
+	// check every setting first so a bad one doesn't leave the others half applied
+	if body.Mode != nil {
+		if err := rankingdata.CheckGameMode(*body.Mode); err != nil {
+			return "", err
+		}
+	}
+	if body.TimeoutDays != nil {
+		if err := rankingdata.CheckTimeout(*body.TimeoutDays); err != nil {
+			return "", err
+		}
+	}
+
+	var before, after []string
+	if body.Mode != nil {
+		before = append(before, "mode: "+c.Mode())
+		if err := c.SetGameMode(*body.Mode); err != nil {
+			return "", err
+		}
+		after = append(after, "mode: "+*body.Mode)
+	}
+	if body.TimeoutDays != nil {
+		before = append(before, fmt.Sprintf("timeout: %d days", c.TimeoutDays()))
+		if err := c.SetTimeout(*body.TimeoutDays); err != nil {
+			return "", err
+		}
+		after = append(after, fmt.Sprintf("timeout: %d days", *body.TimeoutDays))
+	}
+	if len(after) == 0 {
+		return "", errors.New("no settings given")
+	}
+
+	entry.Action = "system_settings"
+	entry.Before = strings.Join(before, ", ")
+	entry.After = strings.Join(after, ", ")
+	return "Updated " + entry.After, nil
+}
+
+func handleAdminResolve(c *rankingdata.ChannelRankingData, r *http.Request, entry *rankingdata.AuditEntry) (string, error) {
+	var body resolveRequest
+	if err := decodeBody(r, &body); err != nil {
+		return "", err
+	}
+	challengeID := r.PathValue("challenge")
+	challenge, err := c.FindChallenge(challengeID)
+	if err != nil {
+		return "", err
+	}
+	message, err := c.ResolveChallengeByID(entry.ActorID, challengeID, body.Result, body.Score)
+	if err != nil {
+		return "", err
+	}
+	// the target is the player the result is resolved for, like on Discord
+	entry.Action = "resolve"
+	entry.TargetID = challenge.DefenderID
+	if body.Result == "cancel" {
+		entry.TargetID = challenge.ChallengerID
+	}
+	entry.Before = fmt.Sprintf("[%s] active", challengeID)
+	entry.After = fmt.Sprintf("[%s] %s", challengeID, body.Result)
+	if body.Score != "" {
+		entry.After += " " + body.Score
+	}
+	return message, nil
+}
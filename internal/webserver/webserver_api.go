@@ -0,0 +1,205 @@
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"discord_ladder_bot/internal/rankingdata"
+)
+
+// JSON representations of the ranking data
+
+type channelJSON struct {
+	ChannelID        string `json:"channel_id"`
+	Mode             string `json:"mode"`
+	Players          int    `json:"players"`
+	ActiveChallenges int    `json:"active_challenges"`
+}
+
+type playerJSON struct {
+	PlayerID     string `json:"player_id"`
+	GameName     string `json:"game_name"`
+	Position     int    `json:"position"`
+	BestPosition int    `json:"best_position,omitempty"`
+	Status       string `json:"status"`
+	Notes        string `json:"notes,omitempty"`
+}
+
+type challengeJSON struct {
+	ChallengeID  string    `json:"challenge_id"`
+	ChallengerID string    `json:"challenger_id"`
+	DefenderID   string    `json:"defender_id"`
+	Date         time.Time `json:"date"`
+	Deadline     time.Time `json:"deadline"`
+}
+
+type resultJSON struct {
+	ChallengeID        string    `json:"challenge_id"`
+	ChallengerID       string    `json:"challenger_id"`
+	DefenderID         string    `json:"defender_id"`
+	ChallengerPosition int       `json:"challenger_position,omitempty"`
+	DefenderPosition   int       `json:"defender_position,omitempty"`
+	Result             string    `json:"result"`
+	ChallengerWon      bool      `json:"challenger_won"`
+	Score              string    `json:"score,omitempty"`
+	ReporterID         string    `json:"reporter_id,omitempty"`
+	Admin              bool      `json:"admin,omitempty"`
+	ChallengeDate      time.Time `json:"challenge_date"`
+	ResolveDate        time.Time `json:"resolve_date"`
+}
+
+type profileJSON struct {
+	playerJSON
+	Tier             int        `json:"tier"`
+	ChallengerWins   int        `json:"challenger_wins"`
+	ChallengerLosses int        `json:"challenger_losses"`
+	DefenderWins     int        `json:"defender_wins"`
+	DefenderLosses   int        `json:"defender_losses"`
+	DefenderForfeits int        `json:"defender_forfeits"`
+	Streak           int        `json:"streak"`
+	LastMatch        *time.Time `json:"last_match,omitempty"`
+}
+
+func newPlayerJSON(player rankingdata.Player) playerJSON {
+	return playerJSON{
+		PlayerID:     player.PlayerID,
+		GameName:     player.GameName,
+		Position:     player.Position,
+		BestPosition: player.BestPosition,
+		Status:       player.Status,
+		Notes:        player.Notes,
+	}
+}
+
+func newChallengeJSON(challenge rankingdata.Challenge) challengeJSON {
+	return challengeJSON{
+		ChallengeID:  challenge.ChallengeID,
+		ChallengerID: challenge.ChallengerID,
+		DefenderID:   challenge.DefenderID,
+		Date:         challenge.ChallengeDate,
+		Deadline:     challenge.ChallengeDeadline,
+	}
+}
+
+func newResultJSON(result rankingdata.ResultHistory) resultJSON {
+	return resultJSON{
+		ChallengeID:        result.ChallengeID,
+		ChallengerID:       result.ChallengerID,
+		DefenderID:         result.DefenderID,
+		ChallengerPosition: result.ChallengerPosition,
+		DefenderPosition:   result.DefenderPosition,
+		Result:             result.Result,
+		ChallengerWon:      result.ChallengerWon(),
+		Score:              result.Score,
+		ReporterID:         result.ReporterID,
+		Admin:              result.Admin,
+		ChallengeDate:      result.ChallengeDate,
+		ResolveDate:        result.ResolveDate,
+	}
+}
+
+// function that registers the read only JSON API routes
+func (web *WebServer) addAPIRoutes() {
+	web.mux.HandleFunc("GET /api/channels", web.handleChannels)
+	web.mux.HandleFunc("GET /api/channels/{channel}/standings", web.handleStandings)
+	web.mux.HandleFunc("GET /api/channels/{channel}/challenges", web.handleChallenges)
+	web.mux.HandleFunc("GET /api/channels/{channel}/history", web.handleHistory)
+	web.mux.HandleFunc("GET /api/channels/{channel}/players/{player}", web.handleProfile)
+}
+
+func (web *WebServer) handleChannels(w http.ResponseWriter, r *http.Request) {
+	channels := []channelJSON{}
+	for _, channelID := range web.RankingData.ChannelIDs() {
+		channel, err := web.RankingData.FindChannel(channelID)
+		if err != nil {
+			// removed since listing
+			continue
+		}
+		channels = append(channels, channelJSON{
+			ChannelID:        channelID,
+			Mode:             channel.Mode(),
+			Players:          len(channel.Standings()),
+			ActiveChallenges: len(channel.Challenges()),
+		})
+	}
+	writeJSON(w, http.StatusOK, channels)
+}
+
+func (web *WebServer) handleStandings(w http.ResponseWriter, r *http.Request) {
+	channel, ok := web.findChannel(w, r)
+	if !ok {
+		return
+	}
+
+	players := []playerJSON{}
+	for _, player := range channel.Standings() {
+		players = append(players, newPlayerJSON(player))
+	}
+	writeJSON(w, http.StatusOK, players)
+}
+
+func (web *WebServer) handleChallenges(w http.ResponseWriter, r *http.Request) {
+	channel, ok := web.findChannel(w, r)
+	if !ok {
+		return
+	}
+
+	challenges := []challengeJSON{}
+	for _, challenge := range channel.Challenges() {
+		challenges = append(challenges, newChallengeJSON(challenge))
+	}
+	writeJSON(w, http.StatusOK, challenges)
+}
+
+func (web *WebServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	channel, ok := web.findChannel(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	results := []resultJSON{}
+	for _, result := range channel.History(limit) {
+		results = append(results, newResultJSON(result))
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (web *WebServer) handleProfile(w http.ResponseWriter, r *http.Request) {
+	channel, ok := web.findChannel(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := channel.PlayerStats(r.PathValue("player"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	profile := profileJSON{
+		playerJSON:       newPlayerJSON(stats.Player),
+		Tier:             stats.Tier,
+		ChallengerWins:   stats.ChallengerWins,
+		ChallengerLosses: stats.ChallengerLosses,
+		DefenderWins:     stats.DefenderWins,
+		DefenderLosses:   stats.DefenderLosses,
+		DefenderForfeits: stats.DefenderForfeits,
+		Streak:           stats.Streak,
+	}
+	profile.BestPosition = stats.BestPosition
+	if !stats.LastMatch.IsZero() {
+		profile.LastMatch = &stats.LastMatch
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
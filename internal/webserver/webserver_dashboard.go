@@ -0,0 +1,144 @@
+package webserver
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"time"
+
+	"discord_ladder_bot/internal/rankingdata"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = map[string]*template.Template{
+	"index":  template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/index.html")),
+	"ladder": template.Must(template.ParseFS(templateFiles, "templates/layout.html", "templates/ladder.html")),
+}
+
+// data passed to the dashboard templates
+
+type indexPage struct {
+	Title    string
+	Now      time.Time
+	Channels []channelJSON
+}
+
+type ladderPage struct {
+	Title      string
+	Now        time.Time
+	ChannelID  string
+	Mode       string
+	Tiers      [][]rankingdata.Player
+	Challenges []challengeRow
+	Results    []resultRow
+}
+
+type challengeRow struct {
+	ChallengeID string
+	Challenger  string
+	Defender    string
+	Date        time.Time
+	Deadline    time.Time
+}
+
+type resultRow struct {
+	ChallengeID string
+	Date        time.Time
+	Challenger  string
+	Defender    string
+	Winner      string
+	Note        string
+	Score       string
+}
+
+// function that registers the HTML dashboard routes
+func (web *WebServer) addDashboardRoutes() {
+	web.mux.HandleFunc("GET /{$}", web.handleIndexPage)
+	web.mux.HandleFunc("GET /ladders/{channel}", web.handleLadderPage)
+}
+
+// function that renders a template, reporting errors as plain text
+func renderPage(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates[name].ExecuteTemplate(w, name+".html", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (web *WebServer) handleIndexPage(w http.ResponseWriter, r *http.Request) {
+	page := indexPage{
+		Title: "Ladders",
+		Now:   time.Now(),
+	}
+	for _, channelID := range web.RankingData.ChannelIDs() {
+		channel, err := web.RankingData.FindChannel(channelID)
+		if err != nil {
+			// removed since listing
+			continue
+		}
+		page.Channels = append(page.Channels, channelJSON{
+			ChannelID:        channelID,
+			Mode:             channel.Mode(),
+			Players:          len(channel.Standings()),
+			ActiveChallenges: len(channel.Challenges()),
+		})
+	}
+	renderPage(w, "index", page)
+}
+
+func (web *WebServer) handleLadderPage(w http.ResponseWriter, r *http.Request) {
+	channel, err := web.RankingData.FindChannel(r.PathValue("channel"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// look up game names, falling back to the ID for players that have left
+	names := map[string]string{}
+	for _, player := range channel.Standings() {
+		names[player.PlayerID] = player.GameName
+	}
+	name := func(playerID string) string {
+		if gameName, ok := names[playerID]; ok {
+			return gameName
+		}
+		return playerID
+	}
+
+	page := ladderPage{
+		Title:     "Ladder " + channel.ChannelID,
+		Now:       time.Now(),
+		ChannelID: channel.ChannelID,
+		Mode:      channel.Mode(),
+		Tiers:     channel.PyramidTiers(),
+	}
+	for _, challenge := range channel.Challenges() {
+		page.Challenges = append(page.Challenges, challengeRow{
+			ChallengeID: challenge.ChallengeID,
+			Challenger:  name(challenge.ChallengerID),
+			Defender:    name(challenge.DefenderID),
+			Date:        challenge.ChallengeDate,
+			Deadline:    challenge.ChallengeDeadline,
+		})
+	}
+	for _, result := range channel.History(20) {
+		row := resultRow{
+			ChallengeID: result.ChallengeID,
+			Date:        result.ResolveDate,
+			Challenger:  name(result.ChallengerID),
+			Defender:    name(result.DefenderID),
+			Winner:      name(result.DefenderID),
+			Score:       result.Score,
+		}
+		if result.ChallengerWon() {
+			row.Winner = name(result.ChallengerID)
+		}
+		if result.Result == "forfeit" || result.Result == "timed out" {
+			row.Note = result.Result
+		}
+		page.Results = append(page.Results, row)
+	}
+	renderPage(w, "ladder", page)
+}
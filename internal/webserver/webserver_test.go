@@ -0,0 +1,161 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"discord_ladder_bot/internal/config"
+	"discord_ladder_bot/internal/rankingdata"
+
+	"github.com/magiconair/properties/assert"
+)
+
+func newTestServer() *WebServer {
+	now := time.Now()
+	data := &rankingdata.RankingData{
+		Version: "v1_test",
+		Channels: []*rankingdata.ChannelRankingData{
+			{ChannelID: "1234", ChallengeMode: "ladder",
+				RankedPlayers: []rankingdata.Player{
+					{PlayerID: "1234", GameName: "u1234", Status: "active", Position: 1},
+					{PlayerID: "5678", GameName: "u5678", Status: "active", Position: 2},
+					{PlayerID: "9012", GameName: "u9012", Status: "active", Position: 3},
+				},
+				ActiveChallenges: []rankingdata.Challenge{
+					{ChallengeID: "3", ChallengerID: "9012", DefenderID: "5678",
+						ChallengeDate: now, ChallengeDeadline: now.Add(24 * time.Hour)},
+				},
+				ResultHistory: []rankingdata.ResultHistory{
+					{ChallengeID: "1", ChallengerID: "5678", DefenderID: "1234", Result: "won"},
+					{ChallengeID: "2", ChallengerID: "9012", DefenderID: "5678", Result: "won"},
+				},
+			}}}
+	return NewWebServer(&config.Config{}, data)
+}
+
+func get(t *testing.T, web *WebServer, path string, v any) int {
+	recorder := httptest.NewRecorder()
+	web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil {
+		if err := json.NewDecoder(recorder.Body).Decode(v); err != nil {
+			t.Fatalf("Error decoding %s: %s", path, err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestAPI(t *testing.T) {
+	web := newTestServer()
+
+	var channels []channelJSON
+	assert.Equal(t, get(t, web, "/api/channels", &channels), http.StatusOK)
+	assert.Equal(t, len(channels), 1)
+	assert.Equal(t, channels[0].Players, 3)
+	assert.Equal(t, channels[0].ActiveChallenges, 1)
+
+	var players []playerJSON
+	assert.Equal(t, get(t, web, "/api/channels/1234/standings", &players), http.StatusOK)
+	assert.Equal(t, len(players), 3)
+	assert.Equal(t, players[0].GameName, "u1234")
+
+	var challenges []challengeJSON
+	assert.Equal(t, get(t, web, "/api/channels/1234/challenges", &challenges), http.StatusOK)
+	assert.Equal(t, len(challenges), 1)
+	assert.Equal(t, challenges[0].ChallengeID, "3")
+
+	// history is newest first and limited
+	var results []resultJSON
+	assert.Equal(t, get(t, web, "/api/channels/1234/history?limit=1", &results), http.StatusOK)
+	assert.Equal(t, len(results), 1)
+	assert.Equal(t, results[0].ChallengeID, "2")
+
+	var profile profileJSON
+	assert.Equal(t, get(t, web, "/api/channels/1234/players/5678", &profile), http.StatusOK)
+	assert.Equal(t, profile.Position, 2)
+	assert.Equal(t, profile.ChallengerLosses, 1)
+	assert.Equal(t, profile.DefenderWins, 1)
+
+	// missing data is a 404
+	assert.Equal(t, get(t, web, "/api/channels/9999/standings", nil), http.StatusNotFound)
+	assert.Equal(t, get(t, web, "/api/channels/1234/players/9999", nil), http.StatusNotFound)
+	assert.Equal(t, get(t, web, "/api/channels/1234/history?limit=x", nil), http.StatusBadRequest)
+}
+
+func TestDashboard(t *testing.T) {
+	web := newTestServer()
+
+	recorder := httptest.NewRecorder()
+	web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	assert.Equal(t, strings.Contains(recorder.Body.String(), `href="/ladders/1234"`), true)
+
+	recorder = httptest.NewRecorder()
+	web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ladders/1234", nil))
+	assert.Equal(t, recorder.Code, http.StatusOK)
+	body := recorder.Body.String()
+	assert.Equal(t, strings.Contains(body, "1. u1234"), false)
+	assert.Equal(t, strings.Contains(body, "<td>u1234</td>"), true)
+	assert.Equal(t, strings.Contains(body, "<td>3</td>"), true)
+
+	recorder = httptest.NewRecorder()
+	web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ladders/9999", nil))
+	assert.Equal(t, recorder.Code, http.StatusNotFound)
+}
+
+func TestAdminAPI(t *testing.T) {
+	web := newTestServer()
+	web.adminToken = "secret"
+	changes := 0
+	web.OnChange = func(*rankingdata.ChannelRankingData) { changes++ }
+
+	request := func(method string, path string, body string, token string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		web.Handler().ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	// requests without the right token are rejected
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players", `{"player_id":"3456"}`, ""), http.StatusUnauthorized)
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players", `{"player_id":"3456"}`, "wrong"), http.StatusUnauthorized)
+	assert.Equal(t, changes, 0)
+
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players", `{"player_id":"3456","game_name":"u3456"}`, "secret"), http.StatusOK)
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players/3456/move", `{"position":1}`, "secret"), http.StatusOK)
+	assert.Equal(t, request("PATCH", "/api/admin/channels/1234/settings", `{"mode":"pyramid","timeout_days":3}`, "secret"), http.StatusOK)
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/challenges/3/resolve", `{"result":"lost","score":"2-0"}`, "secret"), http.StatusOK)
+	assert.Equal(t, request("DELETE", "/api/admin/channels/1234/players/1234", "", "secret"), http.StatusOK)
+	assert.Equal(t, changes, 5)
+
+	// validation is shared with the discord commands
+	assert.Equal(t, request("PATCH", "/api/admin/channels/1234/settings", `{"mode":"chaos"}`, "secret"), http.StatusBadRequest)
+	assert.Equal(t, request("PATCH", "/api/admin/channels/1234/settings", `{"mode":"open","timeout_days":99}`, "secret"), http.StatusBadRequest)
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players/3456/move", `{"position":99}`, "secret"), http.StatusBadRequest)
+	assert.Equal(t, request("POST", "/api/admin/channels/1234/players", `{"player_id":"3456"}`, "secret"), http.StatusBadRequest)
+	assert.Equal(t, changes, 5)
+
+	channel, _ := web.RankingData.FindChannel("1234")
+	assert.Equal(t, channel.Mode(), "pyramid")
+	assert.Equal(t, channel.TimeoutDays(), 3)
+	players := channel.Standings()
+	assert.Equal(t, len(players), 3)
+	assert.Equal(t, players[0].PlayerID, "3456")
+	assert.Equal(t, players[1].PlayerID, "9012")
+
+	// every change is in the audit log
+	assert.Equal(t, len(web.RankingData.AuditLog), 5)
+	assert.Equal(t, web.RankingData.AuditLog[1].Action, "move")
+	assert.Equal(t, web.RankingData.AuditLog[1].Before, "u3456 at position 4")
+	assert.Equal(t, web.RankingData.AuditLog[1].After, "u3456 at position 1")
+	assert.Equal(t, web.RankingData.AuditLog[1].Source, "http")
+	assert.Equal(t, web.RankingData.AuditLog[3].Action, "resolve")
+	assert.Equal(t, web.RankingData.AuditLog[3].After, "[3] lost 2-0")
+	assert.Equal(t, web.RankingData.AuditLog[3].TargetID, "5678")
+}